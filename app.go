@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -11,7 +12,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func NewApp() *App {
+func NewApp(rediscover bool) *App {
 	config, err := LoadConfig()
 	if err != nil {
 		// Use default config if loading fails
@@ -21,7 +22,9 @@ func NewApp() *App {
 	app := &App{
 		state:             StateServerList,
 		servers:           []UpnpDevice{},
+		renderers:         []UpnpDevice{},
 		selectedServer:    -1,
+		selectedRenderer:  -1,
 		currentDirectory:  []string{},
 		directoryContents: []DirectoryItem{},
 		selectedItem:      -1,
@@ -29,19 +32,35 @@ func NewApp() *App {
 		lastError:         "",
 		discoveryErrors:   []string{},
 		isDiscovering:     false,
-		showHelp:          false,
-		showSettings:      false,
-		settingsEditing:   false,
+		opState:           OpIdle,
 		settingsField:     FieldPlayer,
 		settingsInput:     "",
+		menuSelectedItem:  -1,
 		containerIDMap:    make(map[string]string),
 		config:            config,
 		discoveryChan:     make(chan DiscoveryMessage, 100),
+		Queue:             config.Queue,
+		queueSelectedItem: -1,
+		rediscover:        rediscover,
+		browseCache:       newLRUCache(browseCacheCapacity),
 	}
 
 	// Initialize with root container ID
 	app.containerIDMap[""] = "0"
-	
+
+	if len(app.Queue) > 0 {
+		app.queueSelectedItem = 0
+	}
+
+	for _, mount := range config.MOP.Mounts {
+		fs, err := NewMountFS(mount)
+		if err != nil {
+			log.Printf("mount %q: %v", mount.Name, err)
+			continue
+		}
+		app.mounts = append(app.mounts, mountEntry{Name: mount.Name, FS: fs})
+	}
+
 	return app
 }
 
@@ -49,8 +68,7 @@ func (a *App) Init() tea.Cmd {
 	return tea.Batch(
 		a.checkDiscoveryUpdates(),
 		a.tick(),
-		a.periodicDiscovery(),
-		a.startDiscoveryDelayed(),
+		a.startDiscovery(),
 	)
 }
 
@@ -70,15 +88,102 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, nil
 }
 
+// opKeyDispatch maps the active overlay OpState and a key string to the
+// handler that key triggers. Keys not listed here for a non-idle OpState
+// are swallowed rather than falling through to the main navigation
+// bindings below, so an open menu or settings form owns all input until
+// it's explicitly closed.
+var opKeyDispatch = map[OpState]map[string]func(*App) (tea.Model, tea.Cmd){
+	OpHelp: {
+		"?":   func(a *App) (tea.Model, tea.Cmd) { a.opState = OpIdle; return a, nil },
+		"esc": func(a *App) (tea.Model, tea.Cmd) { a.opState = OpIdle; return a, nil },
+	},
+	OpSettings: {
+		"enter": func(a *App) (tea.Model, tea.Cmd) { a.startEditingSettings(); return a, nil },
+		"tab":   func(a *App) (tea.Model, tea.Cmd) { a.nextSettingsField(); return a, nil },
+		",":     func(a *App) (tea.Model, tea.Cmd) { a.opState = OpIdle; return a, nil },
+		"esc":   func(a *App) (tea.Model, tea.Cmd) { a.opState = OpIdle; return a, nil },
+	},
+	OpSettingsEditing: {
+		"enter": func(a *App) (tea.Model, tea.Cmd) {
+			a.saveSettings()
+			a.opState = OpSettings
+			return a, nil
+		},
+		"esc": func(a *App) (tea.Model, tea.Cmd) {
+			a.cancelEditingSettings()
+			a.opState = OpSettings
+			return a, nil
+		},
+		"left": func(a *App) (tea.Model, tea.Cmd) {
+			if a.settingsField == FieldPlayer {
+				a.cyclePlayerSelection(-1)
+			}
+			return a, nil
+		},
+		"right": func(a *App) (tea.Model, tea.Cmd) {
+			if a.settingsField == FieldPlayer {
+				a.cyclePlayerSelection(1)
+			}
+			return a, nil
+		},
+		"backspace": func(a *App) (tea.Model, tea.Cmd) {
+			if a.settingsField != FieldPlayer && len(a.settingsInput) > 0 {
+				a.settingsInput = a.settingsInput[:len(a.settingsInput)-1]
+			}
+			return a, nil
+		},
+	},
+	OpItemMenu: {
+		"up":    func(a *App) (tea.Model, tea.Cmd) { a.menuPrevious(); return a, nil },
+		"k":     func(a *App) (tea.Model, tea.Cmd) { a.menuPrevious(); return a, nil },
+		"down":  func(a *App) (tea.Model, tea.Cmd) { a.menuNext(); return a, nil },
+		"j":     func(a *App) (tea.Model, tea.Cmd) { a.menuNext(); return a, nil },
+		"enter": func(a *App) (tea.Model, tea.Cmd) { a.selectMenuEntry(); return a, nil },
+		"esc":   func(a *App) (tea.Model, tea.Cmd) { a.closeItemMenu(); return a, nil },
+		"m":     func(a *App) (tea.Model, tea.Cmd) { a.closeItemMenu(); return a, nil },
+	},
+	OpDownload: {
+		"esc": func(a *App) (tea.Model, tea.Cmd) { a.cancelDownload(); return a, nil },
+	},
+}
+
 func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "q", "ctrl+c":
+	key := msg.String()
+
+	if key == "ctrl+c" {
+		return a, tea.Quit
+	}
+
+	// While the user is still typing a search query, every key feeds the
+	// query box instead of the normal navigation bindings below.
+	if a.state == StateSearch && !a.searchStarted {
+		return a.handleSearchInputKey(key)
+	}
+
+	if handlers, ok := opKeyDispatch[a.opState]; ok {
+		if handler, ok := handlers[key]; ok {
+			return handler(a)
+		}
+		// Free-text typing into the "close on run" setting doesn't fit a
+		// fixed key table, so it's handled as a fallback here instead.
+		if a.opState == OpSettingsEditing && a.settingsField == FieldCloseOnRun && len(key) == 1 {
+			a.settingsInput += key
+			return a, nil
+		}
+		return a, nil
+	}
+
+	switch key {
+	case "q":
 		return a, tea.Quit
 	case "?":
-		a.toggleHelp()
+		a.opState = OpHelp
 		return a, nil
 	case ",":
-		a.toggleSettings()
+		a.opState = OpSettings
+		a.settingsField = FieldPlayer
+		a.settingsInput = ""
 		return a, nil
 	case "e":
 		if a.hasErrors() {
@@ -96,44 +201,110 @@ func (a *App) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "backspace":
 		a.goBack()
 		return a, nil
-	case "tab":
-		if a.showSettings && !a.settingsEditing {
-			a.nextSettingsField()
+	case "m":
+		if a.state == StateDirectoryBrowser {
+			a.openItemMenu()
+		}
+		return a, nil
+	case "d":
+		if a.state == StateFileDetails && a.selectedItem >= 0 && a.selectedItem < len(a.directoryContents) {
+			a.startDownload(a.directoryContents[a.selectedItem], DownloadModeLocal)
+		}
+		return a, nil
+	case "D":
+		if a.state == StateFileDetails && a.selectedItem >= 0 && a.selectedItem < len(a.directoryContents) {
+			a.startDownload(a.directoryContents[a.selectedItem], DownloadModeTar)
+		}
+		return a, nil
+	case "c":
+		if a.state == StateDirectoryBrowser {
+			a.openRendererSelect()
+		}
+		return a, nil
+	case " ":
+		if a.state == StateTransportControl {
+			a.toggleTransportPlayback()
+		}
+		return a, nil
+	case "s":
+		if a.state == StateTransportControl {
+			a.stopCasting()
+		}
+		return a, nil
+	case "a":
+		if a.state == StateDirectoryBrowser {
+			a.enqueueSelectedItem(false)
+		}
+		return a, nil
+	case "A":
+		if a.state == StateDirectoryBrowser {
+			a.enqueueSelectedItem(true)
+		}
+		return a, nil
+	case "Q":
+		a.toggleQueueView()
+		return a, nil
+	case "/":
+		if a.state == StateDirectoryBrowser {
+			a.openSearch()
+		}
+		return a, nil
+	case "x":
+		if a.state == StateQueue {
+			a.clearQueue()
+		}
+		return a, nil
+	case "shift+enter":
+		if a.state == StateDirectoryBrowser {
+			a.enqueueSelectedItem(false)
 		}
 		return a, nil
 	case "esc":
-		if a.settingsEditing {
-			a.cancelEditingSettings()
+		if a.state == StateSearch {
+			a.goBack()
+		}
+		return a, nil
+	case "left":
+		if a.state == StateTransportControl {
+			a.seekTransport(-10)
+		}
+		return a, nil
+	case "right":
+		if a.state == StateTransportControl {
+			a.seekTransport(10)
 		}
 		return a, nil
 	}
 
-	// Handle settings input
-	if a.showSettings && a.settingsEditing {
-		switch msg.String() {
-		case "enter":
-			a.saveSettings()
-			return a, nil
-		case "backspace":
-			if len(a.settingsInput) > 0 {
-				a.settingsInput = a.settingsInput[:len(a.settingsInput)-1]
-			}
-			return a, nil
-		default:
-			if len(msg.String()) == 1 {
-				a.settingsInput += msg.String()
-			}
+	return a, nil
+}
+
+// handleSearchInputKey feeds a single keystroke into the search query box
+// while the user is still composing it (StateSearch, before Enter kicks
+// off runSearch).
+func (a *App) handleSearchInputKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "enter":
+		a.searchStarted = true
+		a.runSearch(a.searchInput)
+	case "esc", "backspace":
+		if key == "backspace" && len(a.searchInput) > 0 {
+			a.searchInput = a.searchInput[:len(a.searchInput)-1]
 			return a, nil
 		}
+		a.goBack()
+	default:
+		if len(key) == 1 {
+			a.searchInput += key
+		}
 	}
-
 	return a, nil
 }
 
 func (a *App) handleEnter() (tea.Model, tea.Cmd) {
 	switch a.state {
 	case StateServerList:
-		if a.selectedServer >= 0 && a.selectedServer < len(a.servers) {
+		if a.selectedServer >= 0 && a.selectedServer < a.serverCount() {
 			a.state = StateDirectoryBrowser
 			a.currentDirectory = []string{}
 			a.loadDirectory()
@@ -155,10 +326,304 @@ func (a *App) handleEnter() (tea.Model, tea.Cmd) {
 		}
 	case StateFileDetails:
 		a.state = StateDirectoryBrowser
+	case StateRendererSelect:
+		a.selectRenderer()
+	case StateQueue:
+		if a.queueSelectedItem >= 0 && a.queueSelectedItem < len(a.Queue) {
+			item := a.Queue[a.queueSelectedItem]
+			if err := NewPlayerRegistry(a.config.MOP).Play(item, a.config.MOP.CloseOnRun); err != nil {
+				a.lastError = fmt.Sprintf("Failed to play queued item: %v", err)
+			} else {
+				a.lastError = ""
+			}
+		}
+	case StateSearch:
+		if a.searchSelectedItem >= 0 && a.searchSelectedItem < len(a.searchResults) {
+			a.jumpToSearchResult(a.searchResults[a.searchSelectedItem])
+		}
 	}
 	return a, nil
 }
 
+// enqueueSelectedItem enqueues the selected directory entry, inserting it
+// right after the first queue entry instead of at the end when insertNext
+// is true (the "A" keybinding; see insertNextItem).
+func (a *App) enqueueSelectedItem(insertNext bool) {
+	if a.selectedItem < 0 || a.selectedItem >= len(a.directoryContents) {
+		return
+	}
+	item := a.directoryContents[a.selectedItem]
+	if item.IsDirectory {
+		a.lastError = "cannot enqueue a directory"
+		return
+	}
+
+	var err error
+	if insertNext {
+		err = a.insertNextItem(item)
+	} else {
+		err = a.enqueueItem(item)
+	}
+
+	if err != nil {
+		a.lastError = fmt.Sprintf("Failed to enqueue: %v", err)
+	} else {
+		a.lastError = ""
+	}
+}
+
+// toggleQueueView switches between the directory browser and the queue
+// view.
+func (a *App) toggleQueueView() {
+	if a.state == StateQueue {
+		a.state = StateDirectoryBrowser
+		return
+	}
+	a.state = StateQueue
+	if a.queueSelectedItem < 0 && len(a.Queue) > 0 {
+		a.queueSelectedItem = 0
+	}
+}
+
+// openSearch switches to the search overlay with an empty query box. The
+// actual walk (or, with the "all:" prefix, cross-server Search) is only
+// kicked off once the user presses enter, via runSearch.
+func (a *App) openSearch() {
+	a.state = StateSearch
+	a.searchInput = ""
+	a.searchStarted = false
+	a.searching = false
+	a.searchResults = nil
+	a.searchSelectedItem = -1
+}
+
+// jumpToSearchResult leaves the search overlay, selects the server or
+// mount that produced result (result.ServerIndex, into the same combined
+// servers+mounts list currentFS dispatches from), and navigates to
+// result.Path with result.Item preselected.
+func (a *App) jumpToSearchResult(result SearchResult) {
+	if result.ServerIndex < 0 || result.ServerIndex >= a.serverCount() {
+		a.lastError = "server no longer available"
+		return
+	}
+	a.selectedServer = result.ServerIndex
+	a.currentDirectory = append([]string{}, result.Path...)
+	a.state = StateDirectoryBrowser
+	a.loadDirectory()
+	for j, item := range a.directoryContents {
+		if item.Name == result.Item.Name {
+			a.selectedItem = j
+			break
+		}
+	}
+}
+
+// openItemMenu opens the "m" context menu for the currently selected
+// directory entry, offering Play/Enqueue/Copy URL/Download/Show metadata.
+func (a *App) openItemMenu() {
+	if a.selectedItem < 0 || a.selectedItem >= len(a.directoryContents) {
+		return
+	}
+	item := a.directoryContents[a.selectedItem]
+	a.menuTarget = &item
+	a.menuSelectedItem = 0
+	a.opState = OpItemMenu
+}
+
+func (a *App) closeItemMenu() {
+	a.menuTarget = nil
+	a.opState = OpIdle
+}
+
+func (a *App) menuPrevious() {
+	if a.menuSelectedItem <= 0 {
+		a.menuSelectedItem = len(itemMenuEntries) - 1
+	} else {
+		a.menuSelectedItem--
+	}
+}
+
+func (a *App) menuNext() {
+	if a.menuSelectedItem >= len(itemMenuEntries)-1 {
+		a.menuSelectedItem = 0
+	} else {
+		a.menuSelectedItem++
+	}
+}
+
+// selectMenuEntry runs the highlighted item-menu action and closes the
+// menu. "Show metadata" is handled directly here since it just switches
+// to the existing file-details view rather than performing an operation.
+// Download is handled directly too: the menu closes right away, but the
+// transfer itself keeps running under the OpDownload progress overlay
+// startDownload opens.
+func (a *App) selectMenuEntry() {
+	if a.menuTarget == nil || a.menuSelectedItem < 0 || a.menuSelectedItem >= len(itemMenuEntries) {
+		a.closeItemMenu()
+		return
+	}
+
+	entry := itemMenuEntries[a.menuSelectedItem]
+	item := *a.menuTarget
+
+	switch entry.label {
+	case "Show metadata":
+		a.closeItemMenu()
+		a.state = StateFileDetails
+		return
+	case "Download to local path":
+		a.closeItemMenu()
+		a.startDownload(item, DownloadModeLocal)
+		return
+	}
+
+	a.performOperation(entry.op, item)
+}
+
+// performOperation carries out the action a menuEntry was chosen for and
+// returns the app to ordinary directory browsing.
+func (a *App) performOperation(op OpState, item DirectoryItem) {
+	switch op {
+	case OpConfirmPlay:
+		if err := a.playItem(item); err != nil {
+			a.lastError = fmt.Sprintf("Failed to play file: %v", err)
+		} else {
+			a.lastError = ""
+		}
+	case OpEnqueue:
+		if item.IsDirectory {
+			a.lastError = "cannot enqueue a directory"
+		} else if err := a.enqueueItem(item); err != nil {
+			a.lastError = fmt.Sprintf("Failed to enqueue: %v", err)
+		} else {
+			a.lastError = ""
+		}
+	case OpCopyURL:
+		if err := a.copyToClipboard(item.URL); err != nil {
+			a.lastError = fmt.Sprintf("Failed to copy URL: %v", err)
+		} else {
+			a.lastError = "URL copied to clipboard"
+		}
+	}
+
+	a.closeItemMenu()
+}
+
+// downloadDir returns MOPConfig.DownloadDir, falling back to its default
+// for a config predating the field.
+func (a *App) downloadDir() string {
+	if a.config.MOP.DownloadDir != "" {
+		return a.config.MOP.DownloadDir
+	}
+	return defaultDownloadDir()
+}
+
+// openRendererSelect switches to the renderer picker for the currently
+// selected file, instead of invoking the local player.
+func (a *App) openRendererSelect() {
+	if a.selectedItem < 0 || a.selectedItem >= len(a.directoryContents) {
+		return
+	}
+	item := a.directoryContents[a.selectedItem]
+	if item.IsDirectory {
+		a.lastError = "cannot cast a directory"
+		return
+	}
+	if len(a.renderers) == 0 {
+		a.lastError = "no UPnP renderers found"
+		return
+	}
+
+	a.castItem = &item
+	a.selectedRenderer = 0
+	a.state = StateRendererSelect
+}
+
+// selectRenderer casts castItem to the chosen renderer and switches to the
+// transport-control overlay.
+func (a *App) selectRenderer() {
+	if a.selectedRenderer < 0 || a.selectedRenderer >= len(a.renderers) || a.castItem == nil {
+		return
+	}
+
+	renderer := a.renderers[a.selectedRenderer]
+	if err := CastToRenderer(&renderer, *a.castItem); err != nil {
+		a.lastError = fmt.Sprintf("Failed to cast: %v", err)
+		a.state = StateDirectoryBrowser
+		return
+	}
+
+	a.castingTo = &renderer
+	a.transportState = "PLAYING"
+	a.lastError = ""
+	a.state = StateTransportControl
+}
+
+func (a *App) toggleTransportPlayback() {
+	if a.castingTo == nil {
+		return
+	}
+	client, err := NewAVTransportClient(a.castingTo)
+	if err != nil {
+		a.lastError = err.Error()
+		return
+	}
+
+	if a.transportState == "PLAYING" {
+		if err := client.Pause(); err != nil {
+			a.lastError = err.Error()
+			return
+		}
+		a.transportState = "PAUSED_PLAYBACK"
+	} else {
+		if err := client.Play(); err != nil {
+			a.lastError = err.Error()
+			return
+		}
+		a.transportState = "PLAYING"
+	}
+}
+
+func (a *App) stopCasting() {
+	if a.castingTo == nil {
+		a.state = StateDirectoryBrowser
+		return
+	}
+	client, err := NewAVTransportClient(a.castingTo)
+	if err == nil {
+		client.Stop()
+	}
+	a.transportState = "STOPPED"
+	a.castingTo = nil
+	a.castItem = nil
+	a.state = StateDirectoryBrowser
+}
+
+// seekTransport sends the renderer a REL_TIME Seek to a signed
+// "+00:00:SS"/"-00:00:SS" target built from deltaSeconds. mop doesn't
+// track the absolute position, so how the renderer reads that target is
+// up to the renderer.
+func (a *App) seekTransport(deltaSeconds int) {
+	if a.castingTo == nil {
+		return
+	}
+	client, err := NewAVTransportClient(a.castingTo)
+	if err != nil {
+		a.lastError = err.Error()
+		return
+	}
+
+	sign := "+"
+	if deltaSeconds < 0 {
+		sign = "-"
+		deltaSeconds = -deltaSeconds
+	}
+	target := fmt.Sprintf("%s00:00:%02d", sign, deltaSeconds)
+	if err := client.Seek(target); err != nil {
+		a.lastError = err.Error()
+	}
+}
+
 func (a *App) handleDiscoveryMessage(msg DiscoveryMessage) (tea.Model, tea.Cmd) {
 	switch msg.Type {
 	case "started":
@@ -167,6 +632,21 @@ func (a *App) handleDiscoveryMessage(msg DiscoveryMessage) (tea.Model, tea.Cmd)
 		log.Printf("Discovery started, servers count: %d", len(a.servers))
 	case "device_found":
 		if msg.Device != nil {
+			if msg.Device.IsMediaRenderer() && msg.Device.ContentDirectoryURL == "" {
+				found := false
+				for _, renderer := range a.renderers {
+					if renderer.Location == msg.Device.Location {
+						found = true
+						break
+					}
+				}
+				if !found {
+					a.renderers = append(a.renderers, *msg.Device)
+					log.Printf("Added renderer: %s, total renderers: %d", msg.Device.Name, len(a.renderers))
+				}
+				return a, nil
+			}
+
 			// Check for duplicates
 			found := false
 			for _, server := range a.servers {
@@ -182,6 +662,38 @@ func (a *App) handleDiscoveryMessage(msg DiscoveryMessage) (tea.Model, tea.Cmd)
 				log.Printf("Duplicate device ignored: %s", msg.Device.Name)
 			}
 		}
+	case "device_lost":
+		if msg.Device != nil {
+			for i, server := range a.servers {
+				if server.Location == msg.Device.Location {
+					a.servers = append(a.servers[:i], a.servers[i+1:]...)
+					// a.servers precedes a.mounts in the combined server
+					// list, so removing a.servers[i] shifts every index
+					// after it (including mounts) down by one.
+					if a.selectedServer > i {
+						a.selectedServer--
+					} else if a.selectedServer >= a.serverCount() {
+						a.selectedServer = a.serverCount() - 1
+					}
+					break
+				}
+			}
+			// A lost device may equally be a MediaRenderer probed via the
+			// same health-check loop (see Scanner.probeDue), so it has to
+			// be evicted from a.renderers too, not just a.servers.
+			for i, renderer := range a.renderers {
+				if renderer.Location == msg.Device.Location {
+					a.renderers = append(a.renderers[:i], a.renderers[i+1:]...)
+					if a.selectedRenderer > i {
+						a.selectedRenderer--
+					} else if a.selectedRenderer >= len(a.renderers) {
+						a.selectedRenderer = len(a.renderers) - 1
+					}
+					break
+				}
+			}
+			log.Printf("Device lost (failed health probes): %s", msg.Device.Name)
+		}
 	case "error":
 		a.discoveryErrors = append(a.discoveryErrors, msg.Error)
 		a.lastError = msg.Error
@@ -194,6 +706,18 @@ func (a *App) handleDiscoveryMessage(msg DiscoveryMessage) (tea.Model, tea.Cmd)
 		} else {
 			a.lastError = ""
 		}
+	case "search_result":
+		if msg.SearchResult != nil {
+			a.searchResults = append(a.searchResults, *msg.SearchResult)
+			if a.searchSelectedItem < 0 {
+				a.searchSelectedItem = 0
+			}
+		}
+	case "search_completed":
+		a.searching = false
+		a.searchCancel = nil
+	case "download_progress", "download_completed", "download_error":
+		a.handleDownloadMessage(msg)
 	}
 	return a, nil
 }
@@ -201,9 +725,9 @@ func (a *App) handleDiscoveryMessage(msg DiscoveryMessage) (tea.Model, tea.Cmd)
 func (a *App) previous() {
 	switch a.state {
 	case StateServerList:
-		if len(a.servers) > 0 {
+		if a.serverCount() > 0 {
 			if a.selectedServer <= 0 {
-				a.selectedServer = len(a.servers) - 1
+				a.selectedServer = a.serverCount() - 1
 			} else {
 				a.selectedServer--
 			}
@@ -216,14 +740,38 @@ func (a *App) previous() {
 				a.selectedItem--
 			}
 		}
+	case StateRendererSelect:
+		if len(a.renderers) > 0 {
+			if a.selectedRenderer <= 0 {
+				a.selectedRenderer = len(a.renderers) - 1
+			} else {
+				a.selectedRenderer--
+			}
+		}
+	case StateQueue:
+		if len(a.Queue) > 0 {
+			if a.queueSelectedItem <= 0 {
+				a.queueSelectedItem = len(a.Queue) - 1
+			} else {
+				a.queueSelectedItem--
+			}
+		}
+	case StateSearch:
+		if len(a.searchResults) > 0 {
+			if a.searchSelectedItem <= 0 {
+				a.searchSelectedItem = len(a.searchResults) - 1
+			} else {
+				a.searchSelectedItem--
+			}
+		}
 	}
 }
 
 func (a *App) next() {
 	switch a.state {
 	case StateServerList:
-		if len(a.servers) > 0 {
-			if a.selectedServer >= len(a.servers)-1 {
+		if a.serverCount() > 0 {
+			if a.selectedServer >= a.serverCount()-1 {
 				a.selectedServer = 0
 			} else {
 				a.selectedServer++
@@ -237,6 +785,30 @@ func (a *App) next() {
 				a.selectedItem++
 			}
 		}
+	case StateRendererSelect:
+		if len(a.renderers) > 0 {
+			if a.selectedRenderer >= len(a.renderers)-1 {
+				a.selectedRenderer = 0
+			} else {
+				a.selectedRenderer++
+			}
+		}
+	case StateQueue:
+		if len(a.Queue) > 0 {
+			if a.queueSelectedItem >= len(a.Queue)-1 {
+				a.queueSelectedItem = 0
+			} else {
+				a.queueSelectedItem++
+			}
+		}
+	case StateSearch:
+		if len(a.searchResults) > 0 {
+			if a.searchSelectedItem >= len(a.searchResults)-1 {
+				a.searchSelectedItem = 0
+			} else {
+				a.searchSelectedItem++
+			}
+		}
 	}
 }
 
@@ -251,24 +823,25 @@ func (a *App) goBack() {
 		}
 	case StateFileDetails:
 		a.state = StateDirectoryBrowser
-	}
-}
-
-func (a *App) toggleHelp() {
-	a.showHelp = !a.showHelp
-}
-
-func (a *App) toggleSettings() {
-	a.showSettings = !a.showSettings
-	if a.showSettings {
-		a.settingsEditing = false
-		a.settingsField = FieldPlayer
-		a.settingsInput = ""
+	case StateRendererSelect:
+		a.castItem = nil
+		a.state = StateDirectoryBrowser
+	case StateTransportControl:
+		a.state = StateDirectoryBrowser
+	case StateQueue:
+		a.state = StateDirectoryBrowser
+	case StateSearch:
+		if a.searchCancel != nil {
+			a.searchCancel()
+			a.searchCancel = nil
+		}
+		a.searching = false
+		a.state = StateDirectoryBrowser
 	}
 }
 
 func (a *App) startEditingSettings() {
-	a.settingsEditing = true
+	a.opState = OpSettingsEditing
 	switch a.settingsField {
 	case FieldPlayer:
 		a.settingsInput = a.config.MOP.Run
@@ -281,8 +854,27 @@ func (a *App) startEditingSettings() {
 	}
 }
 
+// cyclePlayerSelection moves settingsInput to the next or previous entry in
+// the configured PlayerRegistry, wrapping around at either end.
+func (a *App) cyclePlayerSelection(direction int) {
+	names := NewPlayerRegistry(a.config.MOP).Names()
+	if len(names) == 0 {
+		return
+	}
+
+	index := 0
+	for i, name := range names {
+		if name == a.settingsInput {
+			index = i
+			break
+		}
+	}
+
+	index = (index + direction + len(names)) % len(names)
+	a.settingsInput = names[index]
+}
+
 func (a *App) cancelEditingSettings() {
-	a.settingsEditing = false
 	a.settingsInput = ""
 }
 
@@ -293,7 +885,6 @@ func (a *App) saveSettings() error {
 	case FieldCloseOnRun:
 		a.config.MOP.CloseOnRun = strings.ToLower(a.settingsInput) == "true" || a.settingsInput == "1"
 	}
-	a.settingsEditing = false
 	a.settingsInput = ""
 	return a.config.Save()
 }
@@ -307,20 +898,20 @@ func (a *App) nextSettingsField() {
 }
 
 func (a *App) loadDirectory() {
-	if a.selectedServer < 0 || a.selectedServer >= len(a.servers) {
+	fs, err := a.currentFS()
+	if err != nil {
 		return
 	}
 
-	server := a.servers[a.selectedServer]
-	
-	contents, err := BrowseDirectory(&server, a.currentDirectory, a.containerIDMap)
-	a.directoryContents = contents
+	entries, err := fs.List(context.Background(), strings.Join(a.currentDirectory, "/"))
 	if err != nil {
 		a.lastError = err.Error()
+		a.directoryContents = nil
 	} else {
+		a.directoryContents = entriesToDirectoryItems(fs, entries)
 		a.lastError = ""
 	}
-	
+
 	if len(a.directoryContents) > 0 {
 		a.selectedItem = 0
 	} else {
@@ -332,41 +923,27 @@ func (a *App) playSelectedFile() error {
 	if a.selectedItem < 0 || a.selectedItem >= len(a.directoryContents) {
 		return fmt.Errorf("no file selected")
 	}
+	return a.playItem(a.directoryContents[a.selectedItem])
+}
 
-	item := a.directoryContents[a.selectedItem]
+// playItem plays item, honoring CloseOnRun (run in the foreground and
+// exit mop rather than detaching).
+func (a *App) playItem(item DirectoryItem) error {
 	if item.IsDirectory {
 		return fmt.Errorf("cannot play a directory")
 	}
 
-	if item.URL == "" {
-		return fmt.Errorf("no URL available for this file")
-	}
-
-	return a.invokePlayer(item.URL)
-}
-
-func (a *App) invokePlayer(url string) error {
-	player := a.config.MOP.Run
-	closeOnRun := a.config.MOP.CloseOnRun
-
-	if closeOnRun {
+	if a.config.MOP.CloseOnRun {
 		// Run player in foreground and exit MOP
-		cmd := exec.Command("sh", "-c", fmt.Sprintf("%s '%s'", player, url))
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to start %s: %v", player, err)
-		}
-		
+		err := NewPlayerRegistry(a.config.MOP).Play(item, true)
+		if err != nil {
+			return err
+		}
 		os.Exit(0)
 		return nil // This line will never be reached, but satisfies the compiler
-	} else {
-		// Use nohup to detach player from MOP's process tree
-		cmd := exec.Command("sh", "-c", fmt.Sprintf("nohup %s --really-quiet --no-terminal '%s' > /dev/null 2>&1 &", player, url))
-		return cmd.Run()
 	}
+
+	return NewPlayerRegistry(a.config.MOP).Play(item, false)
 }
 
 func (a *App) hasErrors() bool {
@@ -383,51 +960,73 @@ func (a *App) copyErrorsToClipboard() {
 		errorsText.WriteString(fmt.Sprintf("%d. %s\n", i+1, error))
 	}
 
-	// Try to copy to clipboard using xclip or xsel
+	a.copyToClipboard(errorsText.String())
+	a.lastError = "Errors copied to clipboard"
+}
+
+// copyToClipboard tries xclip, falling back to xsel, to put text on the
+// system clipboard.
+func (a *App) copyToClipboard(text string) error {
 	cmd := exec.Command("xclip", "-selection", "clipboard")
-	cmd.Stdin = strings.NewReader(errorsText.String())
+	cmd.Stdin = strings.NewReader(text)
 	if err := cmd.Run(); err != nil {
-		// Fallback to xsel
 		cmd = exec.Command("xsel", "--clipboard", "--input")
-		cmd.Stdin = strings.NewReader(errorsText.String())
-		cmd.Run()
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
 	}
-
-	a.lastError = "Errors copied to clipboard"
+	return nil
 }
 
-func (a *App) startDiscoveryDelayed() tea.Cmd {
+// startDiscovery looks for a mopd control daemon already listening on the
+// default control socket and, if one is running, relays its shared device
+// cache and live updates into a.discoveryChan instead of this process
+// running its own SSDP sweep (see relayControlDiscovery). Most users never
+// run mopd, so the common case is the fallback: launch the background
+// Scanner directly, exactly as this process always has, taking over both
+// the initial discovery pass and all subsequent re-scanning/health probing.
+func (a *App) startDiscovery() tea.Cmd {
 	return func() tea.Msg {
-		// Start discovery after a short delay to ensure TUI is ready
-		go a.startDiscovery()
+		if client, err := DialControl(defaultSocketPath()); err == nil {
+			a.controlClient = client
+			go a.relayControlDiscovery(client)
+			return nil
+		}
+
+		scanner := NewScanner(a.discoveryChan, a.rediscover)
+		go scanner.Run(context.Background())
 		return nil
 	}
 }
 
-func (a *App) startDiscovery() {
+// relayControlDiscovery seeds a.discoveryChan with mopd's already-discovered
+// servers, then forwards its live watch-devices stream, translating each
+// DeviceEvent back into the DiscoveryMessage shape handleDiscoveryMessage
+// already knows how to apply, so the rest of App can't tell the difference
+// from its own Scanner's messages.
+func (a *App) relayControlDiscovery(client *ControlClient) {
 	a.discoveryChan <- DiscoveryMessage{Type: "started"}
-	
-	// Use callback-based discovery for real-time updates
-	_, errors := DiscoverUpnpDevicesWithCallback(func(device UpnpDevice) {
-		a.discoveryChan <- DiscoveryMessage{
-			Type:   "device_found",
-			Device: &device,
-		}
-	})
-	
-	// Add a small delay to ensure all device messages are processed
-	time.Sleep(100 * time.Millisecond)
-	
-	for _, err := range errors {
-		a.discoveryChan <- DiscoveryMessage{
-			Type:  "error",
-			Error: err,
-		}
-	}
-	
-	// Add another delay before completion
-	time.Sleep(100 * time.Millisecond)
+
+	servers, err := client.ListServers()
+	if err != nil {
+		a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+	}
+	for _, server := range servers {
+		device := server
+		a.discoveryChan <- DiscoveryMessage{Type: "device_found", Device: &device}
+	}
+
+	events, err := client.WatchDevices()
+	if err != nil {
+		a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+		a.discoveryChan <- DiscoveryMessage{Type: "completed"}
+		return
+	}
 	a.discoveryChan <- DiscoveryMessage{Type: "completed"}
+
+	for ev := range events {
+		device := ev.Device
+		a.discoveryChan <- DiscoveryMessage{Type: ev.Type, Device: &device}
+	}
 }
 
 func (a *App) checkDiscoveryUpdates() tea.Cmd {
@@ -449,10 +1048,3 @@ func (a *App) tick() tea.Cmd {
 	})
 }
 
-func (a *App) periodicDiscovery() tea.Cmd {
-	return tea.Tick(time.Second*30, func(t time.Time) tea.Msg {
-		// Restart discovery every 30 seconds
-		go a.startDiscovery()
-		return nil
-	})
-}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// avTransportServiceType is the urn used in the SOAPAction header and the
+// <u:*> element's xmlns for every AVTransport action this client issues.
+const avTransportServiceType = "urn:schemas-upnp-org:service:AVTransport:1"
+
+// AVTransportClient issues UPnP AVTransport SOAP actions against a
+// MediaRenderer's control URL, sharing the envelope/fault handling used by
+// ContentDirectoryClient.
+type AVTransportClient struct {
+	controlURL string
+	client     *http.Client
+}
+
+// NewAVTransportClient builds a client for renderer, returning an error if
+// the device didn't advertise an AVTransport service during discovery.
+func NewAVTransportClient(renderer *UpnpDevice) (*AVTransportClient, error) {
+	if renderer.AVTransportURL == "" {
+		return nil, fmt.Errorf("%s does not expose an AVTransport service", renderer.Name)
+	}
+	return &AVTransportClient{
+		controlURL: renderer.AVTransportURL,
+		client:     &http.Client{Timeout: defaultSOAPTimeout},
+	}, nil
+}
+
+func (c *AVTransportClient) soapAction(action, argsXML string) (string, error) {
+	body, err := soapCall(c.client, c.controlURL, avTransportServiceType, action,
+		"<InstanceID>0</InstanceID>"+argsXML)
+	if err != nil {
+		return "", fmt.Errorf("AVTransport %w", err)
+	}
+	return string(body), nil
+}
+
+// SetAVTransportURI loads url onto the renderer without starting playback.
+func (c *AVTransportClient) SetAVTransportURI(url string) error {
+	args := fmt.Sprintf(`<CurrentURI>%s</CurrentURI><CurrentURIMetaData></CurrentURIMetaData>`, escapeXML(url))
+	_, err := c.soapAction("SetAVTransportURI", args)
+	return err
+}
+
+// Play starts (or resumes) playback at normal speed.
+func (c *AVTransportClient) Play() error {
+	_, err := c.soapAction("Play", `<Speed>1</Speed>`)
+	return err
+}
+
+// Pause suspends playback.
+func (c *AVTransportClient) Pause() error {
+	_, err := c.soapAction("Pause", "")
+	return err
+}
+
+// Stop halts playback and releases the transport.
+func (c *AVTransportClient) Stop() error {
+	_, err := c.soapAction("Stop", "")
+	return err
+}
+
+// Seek jumps to target, expressed as a UPnP REL_TIME string ("HH:MM:SS").
+func (c *AVTransportClient) Seek(target string) error {
+	args := fmt.Sprintf(`<Unit>REL_TIME</Unit><Target>%s</Target>`, escapeXML(target))
+	_, err := c.soapAction("Seek", args)
+	return err
+}
+
+func escapeXML(s string) string {
+	replacer := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		"\"", "&quot;",
+		"'", "&apos;",
+	)
+	return replacer.Replace(s)
+}
+
+// CastToRenderer loads item onto renderer and starts playback in one step —
+// the common case for the "cast" keybinding in the directory browser.
+func CastToRenderer(renderer *UpnpDevice, item DirectoryItem) error {
+	if item.URL == "" {
+		return fmt.Errorf("no URL available for this file")
+	}
+
+	client, err := NewAVTransportClient(renderer)
+	if err != nil {
+		return err
+	}
+
+	if err := client.SetAVTransportURI(item.URL); err != nil {
+		return fmt.Errorf("failed to set transport URI: %w", err)
+	}
+	if err := client.Play(); err != nil {
+		return fmt.Errorf("failed to start playback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is one FS listing result. URL and Metadata are filled in only
+// when the backend already knows a directly playable resource URL for a
+// file (the UPnP/CDS adapter always does, since BrowseDirectory resolves
+// one); Local and WebDAV entries leave them blank and rely on Open
+// instead.
+type Entry struct {
+	Name     string
+	Path     string // slash-separated, relative to the FS root; pass back into List/Stat/Open
+	IsDir    bool
+	Size     int64
+	URL      string
+	Metadata *FileMetadata
+}
+
+// FS is the pluggable browsing/reading surface every entry in
+// renderServerList now dispatches through, whether it's an SSDP-discovered
+// UPnP device or a config [[mop.mount]] pointing at a local directory or a
+// WebDAV share. It mirrors the split Syncthing's Filesystem abstraction
+// makes between Filesystem()/URI()/Type(): URI identifies the backing
+// store, the methods below do the actual browsing and reading.
+type FS interface {
+	// List returns path's children. path is "" for the root.
+	List(ctx context.Context, path string) ([]Entry, error)
+	// Stat returns path's own Entry.
+	Stat(ctx context.Context, path string) (Entry, error)
+	// Open returns a stream of path's content, for backends (Local,
+	// WebDAV) that don't hand back a directly fetchable URL.
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	// URI identifies this FS's backing store: a UPnP device's Location,
+	// a local directory's absolute path, or a WebDAV base URL.
+	URI() string
+}
+
+// NewMountFS builds the FS a MountConfig entry declares, per its Type.
+func NewMountFS(mount MountConfig) (FS, error) {
+	switch mount.Type {
+	case "local":
+		return NewLocalDirFS(mount.URI), nil
+	case "webdav":
+		return NewWebDAVFS(mount.URI, mount.Username, mount.Password), nil
+	default:
+		return nil, fmt.Errorf("mount %q: unknown type %q (want local or webdav)", mount.Name, mount.Type)
+	}
+}
+
+// --- UpnpCDSFS: adapts an UpnpDevice's ContentDirectory service (or a
+// MediaBackend, via BrowseDirectory's own fallback) to FS, so the server
+// list no longer has to special-case "is this a UpnpDevice" once it's
+// past discovery. ---
+
+// UpnpCDSFS wraps the existing BrowseDirectory dispatch (UPnP CDS, then
+// Plex/Jellyfin/Emby, then generic HTTP) behind FS. containerIDMap is
+// shared with the App so repeated browsing into the same path still
+// reuses the real object ID BrowseDirectory resolved the first time.
+type UpnpCDSFS struct {
+	server         *UpnpDevice
+	config         *Config
+	containerIDMap map[string]string
+}
+
+// NewUpnpCDSFS creates an UpnpCDSFS for server, sharing containerIDMap
+// with whatever else browses it (the App's own field, today).
+func NewUpnpCDSFS(server *UpnpDevice, config *Config, containerIDMap map[string]string) *UpnpCDSFS {
+	return &UpnpCDSFS{server: server, config: config, containerIDMap: containerIDMap}
+}
+
+func (fs *UpnpCDSFS) URI() string { return fs.server.Location }
+
+func splitBrowsePath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
+
+func (fs *UpnpCDSFS) List(ctx context.Context, p string) ([]Entry, error) {
+	items, err := BrowseDirectory(fs.server, splitBrowsePath(p), fs.containerIDMap, fs.config)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(items))
+	for _, item := range items {
+		var size int64
+		if item.Metadata != nil && item.Metadata.Size != nil {
+			size = int64(*item.Metadata.Size)
+		}
+		entries = append(entries, Entry{
+			Name:     item.Name,
+			Path:     path.Join(p, item.Name),
+			IsDir:    item.IsDirectory,
+			Size:     size,
+			URL:      item.URL,
+			Metadata: item.Metadata,
+		})
+	}
+	return entries, nil
+}
+
+func (fs *UpnpCDSFS) Stat(ctx context.Context, p string) (Entry, error) {
+	dir, name := path.Split(strings.Trim(p, "/"))
+	entries, err := fs.List(ctx, strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("not found: %s", p)
+}
+
+// Open streams p's resource URL, for callers (e.g. download.go) that want
+// to treat every FS the same way rather than special-casing entries that
+// already carry a URL.
+func (fs *UpnpCDSFS) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	entry, err := fs.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if entry.URL == "" {
+		return nil, fmt.Errorf("%s: no resource URL", p)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, entry.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// --- LocalDirFS: a local directory, useful for testing browsing/playback
+// without a UPnP device and for offline access to content mop already has
+// on disk. ---
+
+// LocalDirFS is an FS rooted at a local directory.
+type LocalDirFS struct {
+	root string
+}
+
+// NewLocalDirFS creates a LocalDirFS rooted at root, an absolute path.
+func NewLocalDirFS(root string) *LocalDirFS {
+	return &LocalDirFS{root: root}
+}
+
+func (fs *LocalDirFS) URI() string { return "file://" + fs.root }
+
+func (fs *LocalDirFS) resolve(p string) string {
+	return filepath.Join(fs.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (fs *LocalDirFS) List(ctx context.Context, p string) ([]Entry, error) {
+	infos, err := os.ReadDir(fs.resolve(p))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(infos))
+	for _, info := range infos {
+		fi, err := info.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, Entry{
+			Name:  info.Name(),
+			Path:  path.Join(p, info.Name()),
+			IsDir: info.IsDir(),
+			Size:  fi.Size(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+func (fs *LocalDirFS) Stat(ctx context.Context, p string) (Entry, error) {
+	info, err := os.Stat(fs.resolve(p))
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Name: info.Name(), Path: p, IsDir: info.IsDir(), Size: info.Size()}, nil
+}
+
+func (fs *LocalDirFS) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	return os.Open(fs.resolve(p))
+}
+
+// LocalPath exposes the resolved filesystem path for p, so callers that
+// can play a local file directly (mpv et al. all accept a bare path) can
+// skip Open's io.Reader round-trip.
+func (fs *LocalDirFS) LocalPath(p string) string {
+	return fs.resolve(p)
+}
+
+// --- WebDAVFS: a WebDAV share, speaking just enough of the protocol
+// (PROPFIND for listing, GET for reading) to browse and stream without a
+// client library dependency. ---
+
+// WebDAVFS is an FS backed by a WebDAV share.
+type WebDAVFS struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAVFS creates a WebDAVFS against baseURL, authenticating with
+// HTTP Basic auth if username is set.
+func NewWebDAVFS(baseURL, username, password string) *WebDAVFS {
+	return &WebDAVFS{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (fs *WebDAVFS) URI() string { return fs.baseURL }
+
+func (fs *WebDAVFS) resolve(p string) string {
+	return fs.baseURL + path.Join("/", p)
+}
+
+func (fs *WebDAVFS) authenticate(req *http.Request) {
+	if fs.username != "" {
+		req.SetBasicAuth(fs.username, fs.password)
+	}
+}
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/></D:prop></D:propfind>`
+
+// webdavMultistatus is the typed shape of a PROPFIND response, parsed the
+// same way didl.go parses DIDL-Lite rather than scraping XML by hand.
+type webdavMultistatus struct {
+	XMLName   xml.Name         `xml:"DAV: multistatus"`
+	Responses []webdavResponse `xml:"response"`
+}
+
+type webdavResponse struct {
+	Href     string         `xml:"href"`
+	Propstat webdavPropstat `xml:"propstat"`
+}
+
+type webdavPropstat struct {
+	Prop webdavProp `xml:"prop"`
+}
+
+type webdavProp struct {
+	ResourceType  webdavResourceType `xml:"resourcetype"`
+	ContentLength int64              `xml:"getcontentlength"`
+}
+
+type webdavResourceType struct {
+	Collection *struct{} `xml:"collection"`
+}
+
+func (fs *WebDAVFS) propfind(ctx context.Context, p string, depth string) (*webdavMultistatus, error) {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", fs.resolve(p), strings.NewReader(webdavPropfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml")
+	fs.authenticate(req)
+
+	resp, err := fs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 207 {
+		return nil, fmt.Errorf("PROPFIND %s: unexpected status %s", p, resp.Status)
+	}
+
+	var ms webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+func (fs *WebDAVFS) List(ctx context.Context, p string) ([]Entry, error) {
+	ms, err := fs.propfind(ctx, p, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	selfHref := strings.TrimSuffix(path.Join("/", p), "/")
+	var entries []Entry
+	for _, r := range ms.Responses {
+		href := strings.TrimSuffix(r.Href, "/")
+		if href == selfHref {
+			continue // PROPFIND Depth:1 includes the collection itself
+		}
+		name := path.Base(href)
+		entries = append(entries, Entry{
+			Name:  name,
+			Path:  path.Join(p, name),
+			IsDir: r.Propstat.Prop.ResourceType.Collection != nil,
+			Size:  r.Propstat.Prop.ContentLength,
+		})
+	}
+	return entries, nil
+}
+
+func (fs *WebDAVFS) Stat(ctx context.Context, p string) (Entry, error) {
+	ms, err := fs.propfind(ctx, p, "0")
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(ms.Responses) == 0 {
+		return Entry{}, fmt.Errorf("not found: %s", p)
+	}
+	r := ms.Responses[0]
+	return Entry{
+		Name:  path.Base(strings.TrimSuffix(r.Href, "/")),
+		Path:  p,
+		IsDir: r.Propstat.Prop.ResourceType.Collection != nil,
+		Size:  r.Propstat.Prop.ContentLength,
+	}, nil
+}
+
+func (fs *WebDAVFS) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fs.resolve(p), nil)
+	if err != nil {
+		return nil, err
+	}
+	fs.authenticate(req)
+
+	resp, err := fs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: unexpected status %s", p, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// PlayableURL returns a URL mpv (or another external player) can open
+// directly for p, embedding HTTP Basic credentials in userinfo the way
+// curl/ffmpeg/mpv all accept.
+func (fs *WebDAVFS) PlayableURL(p string) string {
+	if fs.username == "" {
+		return fs.resolve(p)
+	}
+	u := fs.resolve(p)
+	scheme, rest, ok := strings.Cut(u, "://")
+	if !ok {
+		return u
+	}
+	return fmt.Sprintf("%s://%s:%s@%s", scheme, fs.username, fs.password, rest)
+}
+
+// --- App glue: the server list is now servers (SSDP-discovered UpnpDevices)
+// followed by mounts (configured [[mop.mount]] entries), combined into one
+// virtual list that selectedServer navigates and currentFS dispatches from. ---
+
+// serverCount is the combined length of a.servers and a.mounts, the
+// virtual list renderServerList and selectedServer navigate together.
+func (a *App) serverCount() int {
+	return len(a.servers) + len(a.mounts)
+}
+
+// serverDisplayName returns the name renderServerList shows for index i
+// into the combined servers+mounts list.
+func (a *App) serverDisplayName(i int) string {
+	if i < len(a.servers) {
+		return a.servers[i].Name
+	}
+	return a.mounts[i-len(a.servers)].Name
+}
+
+// currentFS resolves a.selectedServer to the FS it browses: an
+// UpnpCDSFS wrapping the UPnP device for an SSDP-discovered entry, or a
+// configured [[mop.mount]]'s FS directly. This is the dispatch point that
+// used to go straight to BrowseDirectory and a UpnpDevice.
+func (a *App) currentFS() (FS, error) {
+	if a.selectedServer < 0 || a.selectedServer >= a.serverCount() {
+		return nil, fmt.Errorf("no server selected")
+	}
+	if a.selectedServer < len(a.servers) {
+		return NewUpnpCDSFS(&a.servers[a.selectedServer], a.config, a.containerIDMap), nil
+	}
+	return a.mounts[a.selectedServer-len(a.servers)].FS, nil
+}
+
+// entriesToDirectoryItems converts an FS's Entry listing to the
+// DirectoryItems the rest of the app (rendering, play, enqueue, download)
+// already knows how to handle, resolving a playable URL for backends that
+// don't hand one back directly (Local, WebDAV).
+func entriesToDirectoryItems(fs FS, entries []Entry) []DirectoryItem {
+	items := make([]DirectoryItem, 0, len(entries))
+	for _, entry := range entries {
+		url := entry.URL
+		switch backend := fs.(type) {
+		case *LocalDirFS:
+			if !entry.IsDir {
+				url = backend.LocalPath(entry.Path)
+			}
+		case *WebDAVFS:
+			if !entry.IsDir {
+				url = backend.PlayableURL(entry.Path)
+			}
+		}
+
+		metadata := entry.Metadata
+		if metadata == nil && entry.Size > 0 {
+			size := uint64(entry.Size)
+			metadata = &FileMetadata{Size: &size}
+		}
+
+		items = append(items, DirectoryItem{
+			ID:          entry.Path,
+			Name:        entry.Name,
+			IsDirectory: entry.IsDir,
+			URL:         url,
+			Metadata:    metadata,
+		})
+	}
+	return items
+}
@@ -8,23 +8,80 @@ import (
 )
 
 type Config struct {
-	MOP MOPConfig `toml:"mop"`
+	MOP   MOPConfig       `toml:"mop"`
+	Queue []DirectoryItem `toml:"queue"`
 }
 
 type MOPConfig struct {
-	Run        string `toml:"run"`
-	CloseOnRun bool   `toml:"close_on_run"`
+	Run          string                  `toml:"run"`
+	CloseOnRun   bool                    `toml:"close_on_run"`
+	Players      []PlayerConfig          `toml:"players"`
+	MediaServers []MediaServerCredential `toml:"media_servers"`
+	DownloadDir  string                  `toml:"download_dir"`
+	Mounts       []MountConfig           `toml:"mount"`
+}
+
+// MountConfig declares one entry in `[[mop.mount]]`: a non-discovered
+// browsefs.FS that appears in renderServerList alongside SSDP-discovered
+// UPnP devices. Type selects the FS NewMountFS builds ("local" or
+// "webdav"); Username/Password only apply to "webdav".
+type MountConfig struct {
+	Name     string `toml:"name"`
+	Type     string `toml:"type"`
+	URI      string `toml:"uri"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// MediaServerCredential declares one entry in `[[mop.media_servers]]`.
+// Name matches either a discovered UpnpDevice's Name (to target one
+// specific server) or its DeviceClient (to target every server of a
+// given type, e.g. "Plex"). Token is Plex's only supported credential;
+// Jellyfin and Emby also accept Username/Password and exchange it for a
+// session token on login.
+type MediaServerCredential struct {
+	Name     string `toml:"name"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	Token    string `toml:"token"`
+}
+
+// PlayerConfig declares one entry in `[[mop.players]]`. Name selects a
+// built-in backend (mpv, vlc, ffplay, browser); if it doesn't match a
+// built-in, Command/Args describe a custom external player. Formats is the
+// match rule: each entry is either a bare extension ("flac") or a mime
+// prefix ("image/"), checked against the file's extension and the mime
+// type its DIDL-Lite protocolInfo advertises; empty Formats matches
+// anything, for a catch-all entry. The "browser" built-in keeps its own
+// image/text match and ignores Formats.
+type PlayerConfig struct {
+	Name    string   `toml:"name"`
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
+	Formats []string `toml:"formats"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
 		MOP: MOPConfig{
-			Run:        "mpv",
-			CloseOnRun: true,
+			Run:         "mpv",
+			CloseOnRun:  true,
+			DownloadDir: defaultDownloadDir(),
 		},
 	}
 }
 
+// defaultDownloadDir is MOPConfig.DownloadDir's default, ~/Downloads/mop,
+// falling back to a relative "mop-downloads" directory (under the current
+// working directory) if the home directory can't be resolved.
+func defaultDownloadDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "mop-downloads"
+	}
+	return filepath.Join(home, "Downloads", "mop")
+}
+
 func LoadConfig() (*Config, error) {
 	configPath, err := getConfigPath()
 	if err != nil {
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ContentObject is a DIDL-Lite object as a ContentBackend sees it, before
+// mediaserver.go turns it into the DIDL-Lite XML a control point expects.
+type ContentObject struct {
+	ID          string
+	ParentID    string
+	Name        string
+	IsContainer bool
+	Size        int64
+	MimeType    string
+}
+
+// ContentBackend resolves DIDL-Lite ObjectIDs to browsable children and
+// retrievable resources. LocalFSBackend is the only implementation today;
+// a database-backed library or a remote API could plug in here without
+// touching the HTTP/SOAP layer in mediaserver.go.
+type ContentBackend interface {
+	// Children returns objectID's direct children. The root container's
+	// ID is "0".
+	Children(objectID string) ([]ContentObject, error)
+	// Object returns objectID's own metadata.
+	Object(objectID string) (ContentObject, error)
+	// Search returns every descendant of objectID whose name contains
+	// substr, case-insensitively.
+	Search(objectID, substr string) ([]ContentObject, error)
+	// Open returns a seekable reader onto objectID's file content, for
+	// byte-range streaming.
+	Open(objectID string) (io.ReadSeekCloser, error)
+}
+
+// LocalFSBackend serves a local directory tree as DLNA content. ObjectIDs
+// are the slash-separated path relative to Root ("0" is the root itself),
+// which keeps every lookup a single filepath.Join away from the data it
+// actually needs.
+type LocalFSBackend struct {
+	Root string
+}
+
+// NewLocalFSBackend creates a backend rooted at root, an absolute path to
+// the directory to serve.
+func NewLocalFSBackend(root string) *LocalFSBackend {
+	return &LocalFSBackend{Root: root}
+}
+
+func (b *LocalFSBackend) resolve(objectID string) string {
+	if objectID == "" || objectID == "0" {
+		return b.Root
+	}
+	return filepath.Join(b.Root, filepath.FromSlash(objectID))
+}
+
+func (b *LocalFSBackend) toObject(objectID, parentID string, info os.FileInfo) ContentObject {
+	obj := ContentObject{
+		ID:          objectID,
+		ParentID:    parentID,
+		Name:        info.Name(),
+		IsContainer: info.IsDir(),
+		Size:        info.Size(),
+	}
+	if !info.IsDir() {
+		obj.MimeType = mimeTypeFor(info.Name())
+	}
+	return obj
+}
+
+// Children lists objectID's immediate children, directories first and then
+// alphabetically.
+func (b *LocalFSBackend) Children(objectID string) ([]ContentObject, error) {
+	entries, err := os.ReadDir(b.resolve(objectID))
+	if err != nil {
+		return nil, fmt.Errorf("no such container: %s", objectID)
+	}
+
+	parentID := objectID
+	if parentID == "" {
+		parentID = "0"
+	}
+
+	children := make([]ContentObject, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		childID := entry.Name()
+		if objectID != "" && objectID != "0" {
+			childID = objectID + "/" + entry.Name()
+		}
+		children = append(children, b.toObject(childID, parentID, info))
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].IsContainer != children[j].IsContainer {
+			return children[i].IsContainer
+		}
+		return children[i].Name < children[j].Name
+	})
+	return children, nil
+}
+
+// Object returns objectID's own metadata, for BrowseMetadata requests.
+func (b *LocalFSBackend) Object(objectID string) (ContentObject, error) {
+	info, err := os.Stat(b.resolve(objectID))
+	if err != nil {
+		return ContentObject{}, fmt.Errorf("no such object: %s", objectID)
+	}
+	return b.toObject(objectID, parentObjectID(objectID), info), nil
+}
+
+// Search walks every descendant of objectID and returns the ones whose
+// name contains substr, case-insensitively.
+func (b *LocalFSBackend) Search(objectID, substr string) ([]ContentObject, error) {
+	root := b.resolve(objectID)
+	needle := strings.ToLower(substr)
+
+	var matches []ContentObject
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return nil
+		}
+		if !strings.Contains(strings.ToLower(d.Name()), needle) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(b.Root, p)
+		if err != nil {
+			return nil
+		}
+		childID := filepath.ToSlash(rel)
+		matches = append(matches, b.toObject(childID, parentObjectID(childID), info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// Open returns a seekable file handle for objectID, closed by the caller
+// once streaming is done.
+func (b *LocalFSBackend) Open(objectID string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(b.resolve(objectID))
+	if err != nil {
+		return nil, fmt.Errorf("no such object: %s", objectID)
+	}
+	return f, nil
+}
+
+// parentObjectID derives objectID's parent from its path, treating a
+// top-level entry's parent as the root container.
+func parentObjectID(objectID string) string {
+	if idx := strings.LastIndex(objectID, "/"); idx != -1 {
+		return objectID[:idx]
+	}
+	return "0"
+}
+
+// mimeTypeFor guesses a MIME type from name's extension, falling back to
+// a generic binary stream for anything mime doesn't recognize.
+func mimeTypeFor(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
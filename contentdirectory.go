@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// contentDirectoryServiceType is the urn used in both the SOAPAction header
+// and the <u:Browse>/<u:Search> element's xmlns for every ContentDirectory
+// action this client issues.
+const contentDirectoryServiceType = "urn:schemas-upnp-org:service:ContentDirectory:1"
+
+// browseFlag is the UPnP ContentDirectory BrowseFlag argument: either list
+// a container's immediate children, or fetch the metadata of the object
+// itself (used to resolve a single item/container by ID without listing
+// its siblings).
+type browseFlag string
+
+const (
+	BrowseDirectChildren browseFlag = "BrowseDirectChildren"
+	BrowseMetadata       browseFlag = "BrowseMetadata"
+)
+
+// browseRequestedCount is how many objects ContentDirectoryClient asks for
+// per Browse/Search call; it then pages through StartingIndex until
+// TotalMatches is reached rather than capping results at one page.
+const browseRequestedCount = 200
+
+// browseResponse is the <u:BrowseResponse>/<u:SearchResponse> element: both
+// actions share the same response shape (UPnP ContentDirectory:1 spec,
+// section 2.7.4/2.7.18), so one struct decodes either.
+type browseResponse struct {
+	Result         string `xml:"Result"`
+	NumberReturned int    `xml:"NumberReturned"`
+	TotalMatches   int    `xml:"TotalMatches"`
+}
+
+// ContentDirectoryClient issues Browse and Search SOAP actions against a
+// server's ContentDirectory control URL, paging through results with
+// StartingIndex/RequestedCount until TotalMatches is satisfied.
+type ContentDirectoryClient struct {
+	controlURL string
+	client     *http.Client
+}
+
+// NewContentDirectoryClient builds a client for server, returning an error
+// if it didn't advertise a ContentDirectory service during discovery.
+func NewContentDirectoryClient(server *UpnpDevice) (*ContentDirectoryClient, error) {
+	if server.ContentDirectoryURL == "" {
+		return nil, fmt.Errorf("%s does not expose a ContentDirectory service", server.Name)
+	}
+	return &ContentDirectoryClient{
+		controlURL: server.ContentDirectoryURL,
+		client:     &http.Client{Timeout: defaultSOAPTimeout},
+	}, nil
+}
+
+// Browse lists objectID's direct children (or, with BrowseMetadata, fetches
+// objectID's own metadata), following pagination until every match has been
+// collected.
+func (c *ContentDirectoryClient) Browse(objectID string, flag browseFlag) ([]DirectoryItem, error) {
+	return c.browseOrSearch("Browse", fmt.Sprintf(
+		`<ObjectID>%s</ObjectID><BrowseFlag>%s</BrowseFlag>`, escapeXML(objectID), flag))
+}
+
+// Search issues a ContentDirectory Search for searchCriteria (standard
+// UPnP search syntax, see expandSearchQuery) rooted at containerID,
+// following pagination the same way Browse does.
+func (c *ContentDirectoryClient) Search(containerID, searchCriteria string) ([]DirectoryItem, error) {
+	return c.browseOrSearch("Search", fmt.Sprintf(
+		`<ContainerID>%s</ContainerID><SearchCriteria>%s</SearchCriteria>`,
+		escapeXML(containerID), escapeXML(searchCriteria)))
+}
+
+// browseOrSearch drives the StartingIndex/RequestedCount loop shared by
+// Browse and Search: argsPrefix is the action-specific arguments (ObjectID
+// or ContainerID/SearchCriteria), with Filter/StartingIndex/RequestedCount/
+// SortCriteria appended per page.
+func (c *ContentDirectoryClient) browseOrSearch(action, argsPrefix string) ([]DirectoryItem, error) {
+	var all []DirectoryItem
+
+	for startingIndex := 0; ; startingIndex += browseRequestedCount {
+		args := fmt.Sprintf(`%s<Filter>*</Filter><StartingIndex>%d</StartingIndex><RequestedCount>%d</RequestedCount><SortCriteria></SortCriteria>`,
+			argsPrefix, startingIndex, browseRequestedCount)
+
+		body, err := soapCall(c.client, c.controlURL, contentDirectoryServiceType, action, args)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp browseResponse
+		if err := xml.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("%s: decoding response: %w", action, err)
+		}
+
+		page, err := parseDIDLLite(resp.Result)
+		if err != nil {
+			return nil, fmt.Errorf("%s: decoding DIDL-Lite result: %w", action, err)
+		}
+		all = append(all, page...)
+
+		if resp.NumberReturned == 0 || len(all) >= resp.TotalMatches {
+			break
+		}
+	}
+
+	return all, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ControlRequest is a single JSON-RPC-style call sent over the mopd control
+// socket by mopctl or a TUI instance.
+type ControlRequest struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// ControlResponse is the reply to a ControlRequest. Streaming methods (like
+// "watch-errors") write one ControlResponse per event instead of a single
+// reply.
+type ControlResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+}
+
+// BrowseParams are the parameters for the "browse" and "play" control methods.
+type BrowseParams struct {
+	Server string   `json:"server"`
+	Path   []string `json:"path"`
+}
+
+// DeviceEvent is one "watch-devices" stream element: a device that mopd's
+// discovery just added to or dropped from its device cache, so a TUI
+// relaying the stream can apply it through the same device_found/device_lost
+// handling it'd use for its own Scanner.
+type DeviceEvent struct {
+	Type   string     `json:"type"` // "device_found" or "device_lost"
+	Device UpnpDevice `json:"device"`
+}
+
+// defaultSocketPath returns the Unix socket path mopd listens on and mopctl
+// dials by default: $XDG_RUNTIME_DIR/mop.sock, falling back to the system
+// temp dir on systems without a runtime dir.
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "mop.sock")
+	}
+	return filepath.Join(os.TempDir(), "mop.sock")
+}
+
+func encodeParams(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
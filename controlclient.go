@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// ControlClient talks to a running ControlService over its Unix control
+// socket using the JSON-RPC-style protocol defined in control.go.
+type ControlClient struct {
+	conn   net.Conn
+	enc    *json.Encoder
+	dec    *json.Decoder
+	nextID int
+}
+
+// DialControl connects to the mopd control socket at socketPath.
+func DialControl(socketPath string) (*ControlClient, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mopd at %s (is it running?): %w", socketPath, err)
+	}
+	return &ControlClient{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+	}, nil
+}
+
+func (c *ControlClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *ControlClient) call(method string, params interface{}, result interface{}) error {
+	c.nextID++
+	req := ControlRequest{ID: c.nextID, Method: method, Params: encodeParams(params)}
+	if err := c.enc.Encode(req); err != nil {
+		return err
+	}
+
+	var resp ControlResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	if result != nil && resp.Result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// ListServers returns every device mopd has discovered so far.
+func (c *ControlClient) ListServers() ([]UpnpDevice, error) {
+	var servers []UpnpDevice
+	err := c.call("list-servers", nil, &servers)
+	return servers, err
+}
+
+// Browse lists the contents of path on the named server.
+func (c *ControlClient) Browse(server string, path []string) ([]DirectoryItem, error) {
+	var items []DirectoryItem
+	err := c.call("browse", BrowseParams{Server: server, Path: path}, &items)
+	return items, err
+}
+
+// Play asks mopd to invoke the configured player on the file at path.
+func (c *ControlClient) Play(server string, path []string) error {
+	return c.call("play", BrowseParams{Server: server, Path: path}, nil)
+}
+
+// WatchErrors streams discovery errors from mopd until the returned channel
+// is drained and the connection is closed by the caller.
+func (c *ControlClient) WatchErrors() (<-chan string, error) {
+	c.nextID++
+	req := ControlRequest{ID: c.nextID, Method: "watch-errors"}
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		for {
+			var resp ControlResponse
+			if err := c.dec.Decode(&resp); err != nil {
+				return
+			}
+			var msg string
+			if err := json.Unmarshal(resp.Result, &msg); err != nil {
+				continue
+			}
+			events <- msg
+		}
+	}()
+	return events, nil
+}
+
+// WatchDevices streams device_found/device_lost updates from mopd until the
+// returned channel is drained and the connection is closed by the caller.
+// This is what lets a TUI relay mopd's discovery instead of running its own
+// Scanner; see App.relayControlDiscovery.
+func (c *ControlClient) WatchDevices() (<-chan DeviceEvent, error) {
+	c.nextID++
+	req := ControlRequest{ID: c.nextID, Method: "watch-devices"}
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		for {
+			var resp ControlResponse
+			if err := c.dec.Decode(&resp); err != nil {
+				return
+			}
+			var ev DeviceEvent
+			if err := json.Unmarshal(resp.Result, &ev); err != nil {
+				continue
+			}
+			events <- ev
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// ControlService is the long-running discovery/control daemon behind
+// mopctl. It owns the device cache, the container ID map, and the discovery
+// channel that used to live directly on App, so that multiple mopctl
+// invocations and a TUI can share one SSDP sweep instead of each process
+// paying discovery latency on startup.
+type ControlService struct {
+	mu             sync.Mutex
+	servers        []UpnpDevice
+	containerIDMap map[string]string
+	discoveryChan  chan DiscoveryMessage
+	config         *Config
+
+	listener net.Listener
+}
+
+// NewControlService creates an empty control service ready to start
+// discovery and accept connections.
+func NewControlService() *ControlService {
+	config, err := LoadConfig()
+	if err != nil {
+		config = DefaultConfig()
+	}
+
+	svc := &ControlService{
+		containerIDMap: make(map[string]string),
+		discoveryChan:  make(chan DiscoveryMessage, 100),
+		config:         config,
+	}
+	svc.containerIDMap[""] = "0"
+	return svc
+}
+
+// ListenAndServe starts discovery, listens on socketPath, and serves control
+// connections until the listener is closed. It removes a stale socket file
+// left behind by a previous, uncleanly-terminated daemon before binding.
+func (s *ControlService) ListenAndServe(socketPath string) error {
+	if _, err := os.Stat(socketPath); err == nil {
+		if !socketInUse(socketPath) {
+			os.Remove(socketPath)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	s.listener = listener
+	defer listener.Close()
+
+	go s.drainDiscoveryMessages()
+	go s.startDiscovery()
+
+	log.Printf("mopd: control service listening on %s", socketPath)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// socketInUse reports whether a live daemon is already listening on path.
+func socketInUse(path string) bool {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (s *ControlService) startDiscovery() {
+	s.discoveryChan <- DiscoveryMessage{Type: "started"}
+
+	_, errors := DiscoverUpnpDevicesWithCallback(func(device UpnpDevice) {
+		s.discoveryChan <- DiscoveryMessage{Type: "device_found", Device: &device}
+	})
+
+	for _, err := range errors {
+		s.discoveryChan <- DiscoveryMessage{Type: "error", Error: err}
+	}
+
+	s.discoveryChan <- DiscoveryMessage{Type: "completed"}
+}
+
+// drainDiscoveryMessages applies discovery updates to the shared server list
+// and fans them out to every connection currently watching for errors or
+// devices.
+func (s *ControlService) drainDiscoveryMessages() {
+	for msg := range s.discoveryChan {
+		switch {
+		case msg.Type == "device_found" && msg.Device != nil:
+			s.mu.Lock()
+			found := false
+			for _, existing := range s.servers {
+				if existing.Location == msg.Device.Location {
+					found = true
+					break
+				}
+			}
+			if !found {
+				s.servers = append(s.servers, *msg.Device)
+			}
+			s.mu.Unlock()
+		case msg.Type == "device_lost" && msg.Device != nil:
+			s.mu.Lock()
+			for i, existing := range s.servers {
+				if existing.Location == msg.Device.Location {
+					s.servers = append(s.servers[:i], s.servers[i+1:]...)
+					break
+				}
+			}
+			s.mu.Unlock()
+		}
+		s.broadcastErrorWatchers(msg)
+		s.broadcastDeviceWatchers(msg)
+	}
+}
+
+func (s *ControlService) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	for {
+		var req ControlRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		switch req.Method {
+		case "list-servers":
+			s.mu.Lock()
+			servers := append([]UpnpDevice{}, s.servers...)
+			s.mu.Unlock()
+			writeResult(enc, req.ID, servers)
+		case "browse":
+			var params BrowseParams
+			json.Unmarshal(req.Params, &params)
+			items, err := s.browse(params)
+			if err != nil {
+				writeError(enc, req.ID, err)
+				continue
+			}
+			writeResult(enc, req.ID, items)
+		case "play":
+			var params BrowseParams
+			json.Unmarshal(req.Params, &params)
+			if err := s.play(params); err != nil {
+				writeError(enc, req.ID, err)
+				continue
+			}
+			writeResult(enc, req.ID, "ok")
+		case "watch-errors":
+			s.watchErrors(req.ID, enc)
+		case "watch-devices":
+			s.watchDevices(req.ID, enc)
+		default:
+			writeError(enc, req.ID, fmt.Errorf("unknown method %q", req.Method))
+		}
+	}
+}
+
+func (s *ControlService) findServer(name string) (*UpnpDevice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.servers {
+		if s.servers[i].Name == name || s.servers[i].Location == name {
+			return &s.servers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no such server: %s", name)
+}
+
+func (s *ControlService) browse(params BrowseParams) ([]DirectoryItem, error) {
+	server, err := s.findServer(params.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return BrowseDirectory(server, params.Path, s.containerIDMap, s.config)
+}
+
+func (s *ControlService) play(params BrowseParams) error {
+	items, err := s.browse(BrowseParams{Server: params.Server, Path: params.Path[:max(0, len(params.Path)-1)]})
+	if err != nil {
+		return err
+	}
+	if len(params.Path) == 0 {
+		return fmt.Errorf("no file selected")
+	}
+
+	name := params.Path[len(params.Path)-1]
+	for _, item := range items {
+		if item.Name == name && !item.IsDirectory {
+			return NewPlayerRegistry(s.config.MOP).Play(item, false)
+		}
+	}
+
+	return fmt.Errorf("no such file: %s", name)
+}
+
+type errorWatcher struct {
+	id     int
+	events chan string
+}
+
+var (
+	errorWatchersMu sync.Mutex
+	errorWatchers   []*errorWatcher
+)
+
+// watchErrors streams every discovery error to enc as it happens, until a
+// write fails (the client hung up) or the watcher channel is closed.
+func (s *ControlService) watchErrors(id int, enc *json.Encoder) {
+	w := &errorWatcher{id: id, events: make(chan string, 16)}
+
+	errorWatchersMu.Lock()
+	errorWatchers = append(errorWatchers, w)
+	errorWatchersMu.Unlock()
+
+	defer s.removeErrorWatcher(w)
+
+	for msg := range w.events {
+		if err := enc.Encode(ControlResponse{ID: id, Result: encodeParams(msg)}); err != nil {
+			return
+		}
+	}
+}
+
+func (s *ControlService) removeErrorWatcher(w *errorWatcher) {
+	errorWatchersMu.Lock()
+	defer errorWatchersMu.Unlock()
+	for i, existing := range errorWatchers {
+		if existing == w {
+			errorWatchers = append(errorWatchers[:i], errorWatchers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *ControlService) broadcastErrorWatchers(msg DiscoveryMessage) {
+	if msg.Type != "error" {
+		return
+	}
+	errorWatchersMu.Lock()
+	defer errorWatchersMu.Unlock()
+	for _, w := range errorWatchers {
+		select {
+		case w.events <- msg.Error:
+		default:
+		}
+	}
+}
+
+type deviceWatcher struct {
+	id     int
+	events chan DeviceEvent
+}
+
+var (
+	deviceWatchersMu sync.Mutex
+	deviceWatchers   []*deviceWatcher
+)
+
+// watchDevices streams every device_found/device_lost update to enc as it
+// happens, until a write fails (the client hung up) or the watcher channel
+// is closed. A TUI relaying this (see App.relayControlDiscovery) applies
+// each event the same way it would one from its own Scanner.
+func (s *ControlService) watchDevices(id int, enc *json.Encoder) {
+	w := &deviceWatcher{id: id, events: make(chan DeviceEvent, 16)}
+
+	deviceWatchersMu.Lock()
+	deviceWatchers = append(deviceWatchers, w)
+	deviceWatchersMu.Unlock()
+
+	defer s.removeDeviceWatcher(w)
+
+	for ev := range w.events {
+		if err := enc.Encode(ControlResponse{ID: id, Result: encodeParams(ev)}); err != nil {
+			return
+		}
+	}
+}
+
+func (s *ControlService) removeDeviceWatcher(w *deviceWatcher) {
+	deviceWatchersMu.Lock()
+	defer deviceWatchersMu.Unlock()
+	for i, existing := range deviceWatchers {
+		if existing == w {
+			deviceWatchers = append(deviceWatchers[:i], deviceWatchers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *ControlService) broadcastDeviceWatchers(msg DiscoveryMessage) {
+	if (msg.Type != "device_found" && msg.Type != "device_lost") || msg.Device == nil {
+		return
+	}
+	deviceWatchersMu.Lock()
+	defer deviceWatchersMu.Unlock()
+	for _, w := range deviceWatchers {
+		select {
+		case w.events <- DeviceEvent{Type: msg.Type, Device: *msg.Device}:
+		default:
+		}
+	}
+}
+
+func writeResult(enc *json.Encoder, id int, v interface{}) {
+	enc.Encode(ControlResponse{ID: id, Result: encodeParams(v)})
+}
+
+func writeError(enc *json.Encoder, id int, err error) {
+	enc.Encode(ControlResponse{ID: id, Error: err.Error()})
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
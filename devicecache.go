@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// deviceCacheEntry is one on-disk cache row: a previously discovered
+// device plus the wall-clock time its SSDP lease (UpnpDevice.MaxAge)
+// expires at, since a duration alone can't be checked for staleness
+// across a process restart.
+type deviceCacheEntry struct {
+	Device    UpnpDevice
+	ExpiresAt time.Time
+}
+
+// deviceCachePath returns where the on-disk device cache lives:
+// $XDG_CACHE_HOME/mop/devices.json, or the OS cache dir equivalent
+// (os.UserCacheDir honors XDG_CACHE_HOME on Linux).
+func deviceCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "mop", "devices.json"), nil
+}
+
+// loadDeviceCache reads the on-disk cache, returning an empty slice (not
+// an error) if it doesn't exist yet.
+func loadDeviceCache() ([]deviceCacheEntry, error) {
+	path, err := deviceCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []deviceCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// saveDeviceCache writes entries to the on-disk cache, creating its
+// parent directory if needed.
+func saveDeviceCache(entries []deviceCacheEntry) error {
+	path, err := deviceCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// unexpiredDevices filters entries down to those whose lease hasn't
+// elapsed yet.
+func unexpiredDevices(entries []deviceCacheEntry) []UpnpDevice {
+	now := time.Now()
+	var devices []UpnpDevice
+	for _, entry := range entries {
+		if entry.ExpiresAt.After(now) {
+			devices = append(devices, entry.Device)
+		}
+	}
+	return devices
+}
+
+// verifyCachedDevices HEADs every device's Location in parallel and
+// returns only those that answered, so a cold-start cache hit never
+// presents a server that's since gone offline.
+func verifyCachedDevices(devices []UpnpDevice) []UpnpDevice {
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified []UpnpDevice
+	)
+
+	for _, device := range devices {
+		device := device
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodHead, device.Location, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+
+			mu.Lock()
+			verified = append(verified, device)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return verified
+}
@@ -0,0 +1,98 @@
+package main
+
+import "encoding/xml"
+
+// didlLite is the root of a ContentDirectory Browse/Search result document
+// (the payload carried inside the SOAP Result element, itself XML-escaped
+// text that callers must unmarshal a second time).
+type didlLite struct {
+	XMLName    xml.Name     `xml:"DIDL-Lite"`
+	Containers []didlObject `xml:"container"`
+	Items      []didlObject `xml:"item"`
+}
+
+// didlObject covers both <item> and <container> elements: the fields that
+// matter to mop (title, resources) are identical between the two, and the
+// directory/leaf distinction is made by the caller based on which slice a
+// didlObject came from.
+type didlObject struct {
+	ID         string    `xml:"id,attr"`
+	ParentID   string    `xml:"parentID,attr"`
+	ChildCount *int      `xml:"childCount,attr"`
+	Title      string    `xml:"title"`
+	Class      string    `xml:"class"`
+	Resources  []didlRes `xml:"res"`
+}
+
+// didlRes is a <res> element: the actual retrievable resource plus the
+// protocolInfo/size/duration/bitrate/resolution attributes the old
+// string-based parser dropped on the floor.
+type didlRes struct {
+	URL          string  `xml:",chardata"`
+	ProtocolInfo string  `xml:"protocolInfo,attr"`
+	Size         *uint64 `xml:"size,attr"`
+	Duration     string  `xml:"duration,attr"`
+	Bitrate      *uint64 `xml:"bitrate,attr"`
+	Resolution   string  `xml:"resolution,attr"`
+}
+
+// firstResource returns o's first <res> element, or the zero value if it
+// has none (containers usually don't carry a resource of their own).
+func (o didlObject) firstResource() didlRes {
+	if len(o.Resources) == 0 {
+		return didlRes{}
+	}
+	return o.Resources[0]
+}
+
+// directoryItem converts o into the DirectoryItem shape the rest of mop
+// already works with, tagging it as a directory when isContainer is set.
+func (o didlObject) directoryItem(isContainer bool) DirectoryItem {
+	res := o.firstResource()
+
+	item := DirectoryItem{
+		ID:          o.ID,
+		Name:        o.Title,
+		IsDirectory: isContainer,
+		URL:         res.URL,
+	}
+
+	if res.ProtocolInfo != "" || res.Size != nil || res.Duration != "" || res.Bitrate != nil || res.Resolution != "" {
+		meta := &FileMetadata{Size: res.Size}
+		if res.Duration != "" {
+			meta.Duration = &res.Duration
+		}
+		if res.ProtocolInfo != "" {
+			format := res.ProtocolInfo
+			meta.Format = &format
+		}
+		if res.Bitrate != nil {
+			meta.Bitrate = res.Bitrate
+		}
+		if res.Resolution != "" {
+			meta.Resolution = &res.Resolution
+		}
+		item.Metadata = meta
+	}
+
+	return item
+}
+
+// parseDIDLLite unmarshals a DIDL-Lite document (the inner XML of a Browse
+// or Search SOAP response's Result element) into DirectoryItems, containers
+// first so callers that want "directories before files" don't have to sort.
+func parseDIDLLite(doc string) ([]DirectoryItem, error) {
+	var didl didlLite
+	if err := xml.Unmarshal([]byte(doc), &didl); err != nil {
+		return nil, err
+	}
+
+	items := make([]DirectoryItem, 0, len(didl.Containers)+len(didl.Items))
+	for _, c := range didl.Containers {
+		items = append(items, c.directoryItem(true))
+	}
+	for _, i := range didl.Items {
+		items = append(items, i.directoryItem(false))
+	}
+	return items, nil
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DownloadMode selects how downloadTree lays out a pulled CDS subtree,
+// modeled after buildkit's "local" and "tar" exporters: local mirrors the
+// tree as plain files/subdirectories under dest, tar streams it into a
+// single archive at dest instead.
+type DownloadMode string
+
+const (
+	DownloadModeLocal DownloadMode = "local"
+	DownloadModeTar   DownloadMode = "tar"
+)
+
+// downloadClient is shared by every downloadFile/tarAddFile call so a
+// download reuses one connection pool instead of dialing fresh per file.
+var downloadClient = &http.Client{}
+
+// downloadState tracks the one in-flight download the progress overlay
+// renders, if any. cancel stops it from the "esc" key in opKeyDispatch.
+type downloadState struct {
+	file   string
+	dest   string
+	done   int64
+	total  int64
+	cancel context.CancelFunc
+}
+
+// startDownload kicks off a download of item (recursively, if it's a
+// directory) from the currently selected server or mount into
+// a.config.MOP.DownloadDir, reporting progress on a.discoveryChan the same
+// way runSearch reports matches. mode selects the buildkit-style local/tar
+// output layout. It goes through a.currentFS() like the rest of browsing
+// does, so a download works the same whether the selection is an
+// SSDP-discovered UPnP device or a configured [[mop.mount]].
+func (a *App) startDownload(item DirectoryItem, mode DownloadMode) {
+	fs, err := a.currentFS()
+	if err != nil {
+		a.lastError = fmt.Sprintf("download not supported: %v", err)
+		return
+	}
+
+	dest := filepath.Join(a.downloadDir(), filepath.Base(item.Name))
+	if mode == DownloadModeTar {
+		dest += ".tar"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.download = &downloadState{file: item.Name, dest: dest, cancel: cancel}
+	a.opState = OpDownload
+
+	itemPath := item.ID
+
+	go func() {
+		err := downloadToDestination(ctx, fs, itemPath, item, mode, dest, a.discoveryChan)
+		if err != nil {
+			a.discoveryChan <- DiscoveryMessage{Type: "download_error", Error: err.Error()}
+		} else {
+			a.discoveryChan <- DiscoveryMessage{Type: "download_completed"}
+		}
+	}()
+}
+
+// cancelDownload stops the in-flight download and drops the overlay. The
+// goroutine still reports "download_error" (context.Canceled) on its way
+// out. Only a local-mode download fetched over HTTP leaves its partial
+// file as a .part for the next run to resume; a tar archive or a file read
+// through the FS is left truncated at its destination.
+func (a *App) cancelDownload() {
+	if a.download != nil {
+		a.download.cancel()
+	}
+	a.opState = OpIdle
+}
+
+// handleDownloadMessage applies a "download_*" DiscoveryMessage to the
+// progress overlay, called from handleDiscoveryMessage alongside the
+// discovery/search cases that already share discoveryChan.
+func (a *App) handleDownloadMessage(msg DiscoveryMessage) {
+	switch msg.Type {
+	case "download_progress":
+		if a.download != nil && msg.DownloadProgress != nil {
+			a.download.file = msg.DownloadProgress.File
+			a.download.done = msg.DownloadProgress.BytesDone
+			a.download.total = msg.DownloadProgress.TotalBytes
+		}
+	case "download_completed":
+		dest := a.download.dest
+		a.download = nil
+		a.opState = OpIdle
+		a.lastError = fmt.Sprintf("Downloaded to %s", dest)
+	case "download_error":
+		a.download = nil
+		a.opState = OpIdle
+		a.lastError = fmt.Sprintf("Download failed: %s", msg.Error)
+	}
+}
+
+// downloadToDestination pulls item (found at itemPath in fs) into dest
+// according to mode.
+func downloadToDestination(ctx context.Context, fs FS, itemPath string, item DirectoryItem, mode DownloadMode, dest string, progressChan chan<- DiscoveryMessage) error {
+	switch mode {
+	case DownloadModeLocal:
+		if item.IsDirectory {
+			return downloadDirectoryLocal(ctx, fs, itemPath, dest, progressChan)
+		}
+		return downloadLeaf(ctx, fs, item, dest, progressChan)
+
+	case DownloadModeTar:
+		f, err := openTarDestination(dest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		tw := tar.NewWriter(f)
+		defer tw.Close()
+
+		if item.IsDirectory {
+			return downloadDirectoryTar(ctx, fs, itemPath, tw, "", progressChan)
+		}
+		return tarAddLeaf(ctx, fs, item, item.Name, tw, progressChan)
+
+	default:
+		return fmt.Errorf("unknown download mode %q", mode)
+	}
+}
+
+// openTarDestination creates dest (and its parent directory) for a tar
+// archive.
+func openTarDestination(dest string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(dest)
+}
+
+// downloadDirectoryLocal mirrors dirPath's subtree (listed via fs) under
+// destDir, recursing into child directories and writing each leaf file
+// with downloadLeaf.
+func downloadDirectoryLocal(ctx context.Context, fs FS, dirPath string, destDir string, progressChan chan<- DiscoveryMessage) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	entries, err := fs.List(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range entriesToDirectoryItems(fs, entries) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if item.IsDirectory {
+			if err := downloadDirectoryLocal(ctx, fs, item.ID, filepath.Join(destDir, item.Name), progressChan); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := downloadLeaf(ctx, fs, item, filepath.Join(destDir, item.Name), progressChan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadDirectoryTar mirrors dirPath's subtree (listed via fs) into tw
+// under prefix, the archive-relative directory the recursion is currently
+// under.
+func downloadDirectoryTar(ctx context.Context, fs FS, dirPath string, tw *tar.Writer, prefix string, progressChan chan<- DiscoveryMessage) error {
+	entries, err := fs.List(ctx, dirPath)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range entriesToDirectoryItems(fs, entries) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(filepath.Join(prefix, item.Name), "/")
+
+		if item.IsDirectory {
+			if err := tw.WriteHeader(&tar.Header{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Now()}); err != nil {
+				return err
+			}
+			if err := downloadDirectoryTar(ctx, fs, item.ID, tw, name, progressChan); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := tarAddLeaf(ctx, fs, item, name, tw, progressChan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadLeaf writes item (a non-directory entry, found at item.ID in
+// fs) to dest. An item with a fetchable http(s) URL (UPnP/Plex/Jellyfin/
+// Emby, or a WebDAV mount) takes downloadFile's resumable Range-request
+// path; everything else (a LocalDirFS mount, whose URL is a bare
+// filesystem path) streams through fs.Open instead.
+func downloadLeaf(ctx context.Context, fs FS, item DirectoryItem, dest string, progressChan chan<- DiscoveryMessage) error {
+	if isHTTPURL(item.URL) {
+		return downloadFile(ctx, item.URL, dest, progressChan)
+	}
+	return downloadFileFromFS(ctx, fs, item, dest, progressChan)
+}
+
+// tarAddLeaf is downloadLeaf's tar-destination counterpart, writing item
+// into tw as an entry named name.
+func tarAddLeaf(ctx context.Context, fs FS, item DirectoryItem, name string, tw *tar.Writer, progressChan chan<- DiscoveryMessage) error {
+	if isHTTPURL(item.URL) {
+		return tarAddFile(ctx, item.URL, name, tw, progressChan)
+	}
+
+	r, err := fs.Open(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	size := itemSize(item)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: time.Now()}); err != nil {
+		return err
+	}
+	pw := &progressWriter{w: tw, total: size, file: name, progressChan: progressChan}
+	_, err = io.Copy(pw, r)
+	return err
+}
+
+// downloadFileFromFS writes item's content, read via fs.Open, to dest.
+// Unlike downloadFile it can't resume a partial transfer, since a generic
+// io.Reader can't reissue a Range request.
+func downloadFileFromFS(ctx context.Context, fs FS, item DirectoryItem, dest string, progressChan chan<- DiscoveryMessage) error {
+	r, err := fs.Open(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	pw := &progressWriter{w: out, total: itemSize(item), file: filepath.Base(dest), progressChan: progressChan}
+	_, err = io.Copy(pw, r)
+	return err
+}
+
+// isHTTPURL reports whether s is an http(s) URL, as opposed to a bare
+// filesystem path (what LocalDirFS entries carry in DirectoryItem.URL).
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// itemSize returns item's size in bytes, or 0 if its Metadata doesn't
+// carry one (progressWriter treats a 0 total as "unknown").
+func itemSize(item DirectoryItem) int64 {
+	if item.Metadata != nil && item.Metadata.Size != nil {
+		return int64(*item.Metadata.Size)
+	}
+	return 0
+}
+
+// downloadFile streams url to dest via a resumable .part file: an
+// existing .part is resumed with a Range request, and only renamed to
+// dest once the transfer completes, so a Ctrl-C mid-download leaves
+// something the next run can pick back up instead of a truncated dest.
+func downloadFile(ctx context.Context, url, dest string, progressChan chan<- DiscoveryMessage) error {
+	partPath := dest + ".part"
+
+	var resumeFrom int64
+	if info, err := os.Stat(partPath); err == nil {
+		resumeFrom = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if resumeFrom > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resumeFrom))
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		resumeFrom = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected status for %s: %s", url, resp.Status)
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	total := resumeFrom + resp.ContentLength
+	pw := &progressWriter{w: out, written: resumeFrom, total: total, file: filepath.Base(dest), progressChan: progressChan}
+	if _, err := io.Copy(pw, resp.Body); err != nil {
+		return err
+	}
+
+	return os.Rename(partPath, dest)
+}
+
+// tarAddFile streams url straight into a tar entry named name. Unlike
+// downloadFile it can't resume a partial archive write, so a failure here
+// fails the whole tar download.
+func tarAddFile(ctx context.Context, url, name string, tw *tar.Writer, progressChan chan<- DiscoveryMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status for %s: %s", url, resp.Status)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: resp.ContentLength, ModTime: time.Now()}); err != nil {
+		return err
+	}
+
+	pw := &progressWriter{w: tw, total: resp.ContentLength, file: name, progressChan: progressChan}
+	_, err = io.Copy(pw, resp.Body)
+	return err
+}
+
+// progressWriter wraps an io.Writer, reporting cumulative bytes written
+// for file as a "download_progress" DiscoveryMessage after every chunk
+// io.Copy hands it.
+type progressWriter struct {
+	w            io.Writer
+	written      int64
+	total        int64
+	file         string
+	progressChan chan<- DiscoveryMessage
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	pw.progressChan <- DiscoveryMessage{
+		Type: "download_progress",
+		DownloadProgress: &DownloadProgress{
+			File:       pw.file,
+			BytesDone:  pw.written,
+			TotalBytes: pw.total,
+		},
+	}
+	return n, err
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// igdDeviceType is the ST mop searches for when looking for a router's
+// InternetGatewayDevice description, the entry point to its
+// WANIPConnection/WANPPPConnection service.
+const igdDeviceType = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+
+// igdServiceNames are the WAN connection service types that expose
+// AddPortMapping/DeletePortMapping/GetExternalIPAddress, checked in the
+// order a home router is most likely to advertise them.
+var igdServiceNames = []string{"WANIPConnection", "WANPPPConnection"}
+
+// UPnPIGDClient is a NATClient backed by a router's UPnP
+// InternetGatewayDevice WANIPConnection/WANPPPConnection service, calling
+// it the same way ContentDirectoryClient/AVTransportClient call their
+// services: soapCall against a control URL resolved from the device's SCPD.
+type UPnPIGDClient struct {
+	client      *http.Client
+	controlURL  string
+	serviceType string
+}
+
+// discoverIGD locates the first InternetGatewayDevice on the LAN that
+// advertises a WAN connection service, within timeout.
+func discoverIGD(timeout time.Duration) (NATClient, error) {
+	location, err := searchIGD(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := fetchDeviceDescription(location)
+	if err != nil {
+		return nil, fmt.Errorf("fetching IGD description: %w", err)
+	}
+
+	baseURL := extractBaseURL(location)
+	for _, name := range igdServiceNames {
+		svc, ok := desc.Device.findService(name)
+		if !ok {
+			continue
+		}
+		controlURL := svc.ControlURL
+		if !strings.HasPrefix(controlURL, "http") {
+			controlURL = baseURL + controlURL
+		}
+		return &UPnPIGDClient{
+			client:      &http.Client{Timeout: defaultSOAPTimeout},
+			controlURL:  controlURL,
+			serviceType: svc.ServiceType,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("IGD at %s advertises no WANIPConnection/WANPPPConnection service", location)
+}
+
+// searchIGD fans an M-SEARCH for igdDeviceType out over every
+// multicast-capable interface the same way discoverViaSSDPWithCallback
+// does, and returns the LOCATION of whichever IGD answers first.
+func searchIGD(timeout time.Duration) (string, error) {
+	ifaces := multicastInterfaces()
+	if len(ifaces) == 0 {
+		return "", fmt.Errorf("no multicast-capable network interfaces found")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	found := make(chan string, 1)
+	request := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"ST: " + igdDeviceType + "\r\n" +
+		"MX: " + strconv.Itoa(ssdpSearchMX) + "\r\n\r\n"
+
+	for _, iface := range ifaces {
+		iface := iface
+		go func() {
+			listener, err := newSSDPListener(iface)
+			if err != nil {
+				return
+			}
+			defer listener.Close()
+
+			listener.conn.WriteTo([]byte(request), ssdpGroupAddr)
+			listener.run(ctx, func(message string) {
+				if !strings.HasPrefix(message, "HTTP/1.1 200 OK") {
+					return
+				}
+				if !strings.Contains(extractSSDPHeader(message, "st"), "InternetGatewayDevice") {
+					return
+				}
+				if location := extractSSDPHeader(message, "location"); location != "" {
+					select {
+					case found <- location:
+					default:
+					}
+				}
+			})
+		}()
+	}
+
+	select {
+	case location := <-found:
+		return location, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("no InternetGatewayDevice responded within %s", timeout)
+	}
+}
+
+// extractSSDPHeader returns the value of the named header (matched
+// case-insensitively) from a raw SSDP message, or "" if it's absent.
+func extractSSDPHeader(message, name string) string {
+	for _, line := range strings.Split(message, "\r\n") {
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:idx]), name) {
+			return strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return ""
+}
+
+func (c *UPnPIGDClient) Name() string { return "UPnP IGD" }
+
+// AddPortMapping calls WANIPConnection's AddPortMapping action, mapping
+// this host's internalPort to externalPort on the router's WAN interface
+// for lifetime (rounded to whole seconds, as NewLeaseDuration requires).
+func (c *UPnPIGDClient) AddPortMapping(proto string, internalPort, externalPort int, lifetime time.Duration) error {
+	internalClient, err := outboundIP()
+	if err != nil {
+		return err
+	}
+
+	args := fmt.Sprintf(
+		"<NewRemoteHost></NewRemoteHost>"+
+			"<NewExternalPort>%d</NewExternalPort>"+
+			"<NewProtocol>%s</NewProtocol>"+
+			"<NewInternalPort>%d</NewInternalPort>"+
+			"<NewInternalClient>%s</NewInternalClient>"+
+			"<NewEnabled>1</NewEnabled>"+
+			"<NewPortMappingDescription>mop</NewPortMappingDescription>"+
+			"<NewLeaseDuration>%d</NewLeaseDuration>",
+		externalPort, strings.ToUpper(proto), internalPort, internalClient, int(lifetime.Seconds()))
+
+	_, err = soapCall(c.client, c.controlURL, c.serviceType, "AddPortMapping", args)
+	return err
+}
+
+// DeletePortMapping calls WANIPConnection's DeletePortMapping action,
+// removing a mapping previously created with AddPortMapping.
+func (c *UPnPIGDClient) DeletePortMapping(proto string, externalPort int) error {
+	args := fmt.Sprintf(
+		"<NewRemoteHost></NewRemoteHost><NewExternalPort>%d</NewExternalPort><NewProtocol>%s</NewProtocol>",
+		externalPort, strings.ToUpper(proto))
+
+	_, err := soapCall(c.client, c.controlURL, c.serviceType, "DeletePortMapping", args)
+	return err
+}
+
+// getExternalIPAddressResponse is WANIPConnection's GetExternalIPAddress
+// response; like browseResponse it has no XMLName so Unmarshal matches
+// its fields regardless of the wrapping <u:...Response> element's name.
+type getExternalIPAddressResponse struct {
+	NewExternalIPAddress string `xml:"NewExternalIPAddress"`
+}
+
+// ExternalIP calls WANIPConnection's GetExternalIPAddress action.
+func (c *UPnPIGDClient) ExternalIP() (net.IP, error) {
+	body, err := soapCall(c.client, c.controlURL, c.serviceType, "GetExternalIPAddress", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var resp getExternalIPAddressResponse
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding GetExternalIPAddress response: %w", err)
+	}
+
+	ip := net.ParseIP(resp.NewExternalIPAddress)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external IP %q", resp.NewExternalIPAddress)
+	}
+	return ip, nil
+}
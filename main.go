@@ -8,8 +8,42 @@ import (
 )
 
 func main() {
-	app := NewApp()
-	
+	args := os.Args[1:]
+
+	if len(args) > 0 && args[0] == "ctl" {
+		if err := runMopctl(args[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "serve" {
+		if err := runServe(args[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "--control-daemon" {
+		svc := NewControlService()
+		if err := svc.ListenAndServe(defaultSocketPath()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	rediscover := false
+	for _, arg := range args {
+		if arg == "--rediscover" {
+			rediscover = true
+		}
+	}
+
+	app := NewApp(rediscover)
+
 	p := tea.NewProgram(app, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v\n", err)
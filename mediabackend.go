@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MediaCredentials is what a MediaBackend needs to authenticate, read out
+// of the matching entry in MOPConfig.MediaServers. Plex only ever uses
+// Token; Jellyfin/Emby use Username/Password and fill in Token themselves
+// once Login succeeds.
+type MediaCredentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// MediaBackend is a media server's authenticated HTTP API, abstracted the
+// same way Player abstracts a playback command: BrowseDirectory dispatches
+// to one based on a discovered UpnpDevice's DeviceClient instead of
+// falling back to browseHTTPDirectory's single stub "Media Library" entry.
+type MediaBackend interface {
+	// Login authenticates against the server, if the backend needs to
+	// (Plex only needs creds.Token to already be set; Jellyfin/Emby
+	// exchange Username/Password for a session token here).
+	Login(creds MediaCredentials) error
+	// Libraries lists the server's top-level libraries/sections, as the
+	// root container's children.
+	Libraries() ([]DirectoryItem, error)
+	// Browse lists id's children. id is one of the IDs Libraries or a
+	// previous Browse returned.
+	Browse(id string) ([]DirectoryItem, error)
+	// StreamURL resolves id to a directly playable URL.
+	StreamURL(id string) (string, error)
+}
+
+// mediaBackendFor maps the DeviceClient discovery sets (see buildDevice
+// and scanEndpoint) to the MediaBackend that knows how to talk to it.
+func mediaBackendFor(server *UpnpDevice) MediaBackend {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch server.DeviceClient {
+	case "Plex":
+		return NewPlexBackend(server.BaseURL, client)
+	case "Jellyfin":
+		return newEmbyFamilyBackend(server.BaseURL, client, "Jellyfin")
+	case "Emby":
+		return newEmbyFamilyBackend(server.BaseURL, client, "Emby")
+	default:
+		return nil
+	}
+}
+
+// NewMediaBackend builds the MediaBackend for server and logs it in using
+// whatever MediaServers entry in config matches, returning an error if
+// server's DeviceClient has no known backend or login fails.
+func NewMediaBackend(server *UpnpDevice, config *Config) (MediaBackend, error) {
+	backend := mediaBackendFor(server)
+	if backend == nil {
+		return nil, fmt.Errorf("no media backend for %s", server.DeviceClient)
+	}
+
+	creds := config.credentialsFor(server)
+	if err := backend.Login(creds); err != nil {
+		return nil, fmt.Errorf("logging into %s: %w", server.Name, err)
+	}
+	return backend, nil
+}
+
+// credentialsFor returns the MediaCredentials for server out of
+// c.MOP.MediaServers, matching by device name first and DeviceClient
+// second so a user can target one specific server or every server of a
+// given type with one entry. The zero value is returned if there's no
+// match, which is enough for a server that needs no credentials at all.
+func (c *Config) credentialsFor(server *UpnpDevice) MediaCredentials {
+	var byClient *MediaServerCredential
+	for i := range c.MOP.MediaServers {
+		entry := &c.MOP.MediaServers[i]
+		if entry.Name == server.Name {
+			return entry.credentials()
+		}
+		if entry.Name == server.DeviceClient {
+			byClient = entry
+		}
+	}
+	if byClient != nil {
+		return byClient.credentials()
+	}
+	return MediaCredentials{}
+}
+
+// credentials converts an [[mop.media_servers]] TOML entry into the
+// MediaCredentials a MediaBackend's Login expects.
+func (m MediaServerCredential) credentials() MediaCredentials {
+	return MediaCredentials{Username: m.Username, Password: m.Password, Token: m.Token}
+}
+
+// ---- Plex ----
+
+// PlexBackend talks to a Plex Media Server's HTTP API, authenticating
+// every request with an X-Plex-Token query parameter.
+type PlexBackend struct {
+	baseURL string
+	client  *http.Client
+	token   string
+}
+
+// NewPlexBackend creates a PlexBackend for the server at baseURL; call
+// Login before Libraries/Browse/StreamURL.
+func NewPlexBackend(baseURL string, client *http.Client) *PlexBackend {
+	return &PlexBackend{baseURL: baseURL, client: client}
+}
+
+// Login stores creds.Token, Plex's only supported credential, and checks
+// it actually works by listing sections with it.
+func (b *PlexBackend) Login(creds MediaCredentials) error {
+	if creds.Token == "" {
+		return fmt.Errorf("Plex requires a token (set [[mop.media_servers]] token = \"...\")")
+	}
+	b.token = creds.Token
+	_, err := b.Libraries()
+	return err
+}
+
+// plexMediaContainer is the root element Plex wraps every XML response in,
+// whether it's a list of library sections or a directory's contents.
+type plexMediaContainer struct {
+	XMLName     xml.Name        `xml:"MediaContainer"`
+	Directories []plexDirectory `xml:"Directory"`
+	Videos      []plexVideo     `xml:"Video"`
+}
+
+// plexDirectory is one <Directory> element: a library section at the
+// top level, or a folder/show while browsing into one.
+type plexDirectory struct {
+	Key   string `xml:"key,attr"`
+	Title string `xml:"title,attr"`
+}
+
+// plexVideo is one <Video> element: a playable item, whose actual file
+// lives at Media.Part.Key relative to the server's baseURL.
+type plexVideo struct {
+	RatingKey string      `xml:"ratingKey,attr"`
+	Title     string      `xml:"title,attr"`
+	Media     []plexMedia `xml:"Media"`
+}
+
+type plexMedia struct {
+	Parts []plexPart `xml:"Part"`
+}
+
+type plexPart struct {
+	Key string `xml:"key,attr"`
+}
+
+// Libraries lists Plex's library sections as the root container's
+// children.
+func (b *PlexBackend) Libraries() ([]DirectoryItem, error) {
+	container, err := b.get("/library/sections")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]DirectoryItem, 0, len(container.Directories))
+	for _, dir := range container.Directories {
+		items = append(items, DirectoryItem{ID: dir.Key, Name: dir.Title, IsDirectory: true})
+	}
+	return items, nil
+}
+
+// Browse lists a library section's (or folder's) contents. id is either a
+// section key as returned by Libraries, or a folder's absolute key path
+// (starting with "/") as returned by an earlier Browse.
+func (b *PlexBackend) Browse(id string) ([]DirectoryItem, error) {
+	path := fmt.Sprintf("/library/sections/%s/all", id)
+	if strings.HasPrefix(id, "/") {
+		path = id
+	}
+	container, err := b.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]DirectoryItem, 0, len(container.Directories)+len(container.Videos))
+	for _, dir := range container.Directories {
+		items = append(items, DirectoryItem{ID: dir.Key, Name: dir.Title, IsDirectory: true})
+	}
+	for _, video := range container.Videos {
+		streamURL, err := b.StreamURL(video.partKey())
+		if err != nil {
+			continue
+		}
+		items = append(items, DirectoryItem{ID: video.RatingKey, Name: video.Title, URL: streamURL})
+	}
+	return items, nil
+}
+
+// partKey returns v's first playable Media.Part key, or "" if Plex didn't
+// report one (a collection or an item still being analyzed, say).
+func (v plexVideo) partKey() string {
+	if len(v.Media) == 0 || len(v.Media[0].Parts) == 0 {
+		return ""
+	}
+	return v.Media[0].Parts[0].Key
+}
+
+// StreamURL turns a /library/parts/... part key (as returned embedded in
+// Browse's Video entries) into a direct-play URL authenticated with
+// X-Plex-Token.
+func (b *PlexBackend) StreamURL(id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("no playable part for this item")
+	}
+	sep := "?"
+	if strings.Contains(id, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s%sX-Plex-Token=%s", b.baseURL, id, sep, url.QueryEscape(b.token)), nil
+}
+
+func (b *PlexBackend) get(path string) (*plexMediaContainer, error) {
+	req, err := http.NewRequest(http.MethodGet, b.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Plex-Token", b.token)
+	req.Header.Set("Accept", "application/xml")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Plex returned %s for %s", resp.Status, path)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var container plexMediaContainer
+	if err := xml.Unmarshal(body, &container); err != nil {
+		return nil, fmt.Errorf("parsing Plex response: %w", err)
+	}
+	return &container, nil
+}
+
+// ---- Jellyfin / Emby ----
+
+// embyFamilyBackend implements MediaBackend for Jellyfin and Emby, whose
+// APIs are close enough (Jellyfin is a fork of Emby's server) to share one
+// implementation parameterized by clientName, which is all that changes
+// in the X-Emby-Authorization header and the default port each server
+// publishes it under.
+type embyFamilyBackend struct {
+	baseURL    string
+	client     *http.Client
+	clientName string // "Jellyfin" or "Emby", sent in X-Emby-Authorization
+	token      string
+	userID     string
+}
+
+func newEmbyFamilyBackend(baseURL string, client *http.Client, clientName string) *embyFamilyBackend {
+	return &embyFamilyBackend{baseURL: baseURL, client: client, clientName: clientName}
+}
+
+// NewJellyfinBackend creates an embyFamilyBackend talking Jellyfin's API.
+func NewJellyfinBackend(baseURL string, client *http.Client) MediaBackend {
+	return newEmbyFamilyBackend(baseURL, client, "Jellyfin")
+}
+
+// NewEmbyBackend creates an embyFamilyBackend talking Emby's API.
+func NewEmbyBackend(baseURL string, client *http.Client) MediaBackend {
+	return newEmbyFamilyBackend(baseURL, client, "Emby")
+}
+
+// embyAuthResponse is what /Users/AuthenticateByName returns on success.
+type embyAuthResponse struct {
+	AccessToken string `json:"AccessToken"`
+	User        struct {
+		ID string `json:"Id"`
+	} `json:"User"`
+}
+
+// embyItem is one entry in an Items or Views response: a library, a
+// folder, or a playable file, distinguished by IsFolder.
+type embyItem struct {
+	ID       string `json:"Id"`
+	Name     string `json:"Name"`
+	IsFolder bool   `json:"IsFolder"`
+}
+
+// embyItemsResponse wraps both /Users/{id}/Views and
+// /Users/{id}/Items, which share this shape.
+type embyItemsResponse struct {
+	Items []embyItem `json:"Items"`
+}
+
+// Login exchanges creds.Username/Password for a session token via
+// /Users/AuthenticateByName, unless creds.Token is already set.
+func (b *embyFamilyBackend) Login(creds MediaCredentials) error {
+	if creds.Token != "" {
+		b.token = creds.Token
+		return b.resolveUserID()
+	}
+	if creds.Username == "" {
+		return fmt.Errorf("%s requires a username/password or token in [[mop.media_servers]]", b.clientName)
+	}
+
+	payload, err := json.Marshal(map[string]string{"Username": creds.Username, "Pw": creds.Password})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.baseURL+"/Users/AuthenticateByName", strings.NewReader(string(payload)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Emby-Authorization", b.authHeader())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s authentication failed: %s", b.clientName, resp.Status)
+	}
+
+	var auth embyAuthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return fmt.Errorf("parsing %s authentication response: %w", b.clientName, err)
+	}
+
+	b.token = auth.AccessToken
+	b.userID = auth.User.ID
+	return nil
+}
+
+// resolveUserID looks up the user ID for a token supplied directly in
+// config (skipping AuthenticateByName), via the current-user endpoint
+// both Jellyfin and Emby expose.
+func (b *embyFamilyBackend) resolveUserID() error {
+	req, err := http.NewRequest(http.MethodGet, b.baseURL+"/Users/Me", nil)
+	if err != nil {
+		return err
+	}
+	b.authenticate(req)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s rejected the configured token: %s", b.clientName, resp.Status)
+	}
+
+	var user struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return fmt.Errorf("parsing %s user response: %w", b.clientName, err)
+	}
+	b.userID = user.ID
+	return nil
+}
+
+// authHeader builds the X-Emby-Authorization header every Jellyfin/Emby
+// request needs, even before login.
+func (b *embyFamilyBackend) authHeader() string {
+	return fmt.Sprintf(`MediaBrowser Client="mop", Device="mop", DeviceId="mop", Version="1.0", Client="%s"`, b.clientName)
+}
+
+// Libraries lists the signed-in user's top-level views (their libraries).
+func (b *embyFamilyBackend) Libraries() ([]DirectoryItem, error) {
+	return b.listItems(fmt.Sprintf("/Users/%s/Views", b.userID))
+}
+
+// Browse lists id's children via /Users/{uid}/Items?ParentId=id.
+func (b *embyFamilyBackend) Browse(id string) ([]DirectoryItem, error) {
+	return b.listItems(fmt.Sprintf("/Users/%s/Items?ParentId=%s", b.userID, url.QueryEscape(id)))
+}
+
+func (b *embyFamilyBackend) listItems(path string) ([]DirectoryItem, error) {
+	req, err := http.NewRequest(http.MethodGet, b.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.authenticate(req)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s for %s", b.clientName, resp.Status, path)
+	}
+
+	var parsed embyItemsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("parsing %s response: %w", b.clientName, err)
+	}
+
+	items := make([]DirectoryItem, 0, len(parsed.Items))
+	for _, item := range parsed.Items {
+		di := DirectoryItem{ID: item.ID, Name: item.Name, IsDirectory: item.IsFolder}
+		if !item.IsFolder {
+			if streamURL, err := b.StreamURL(item.ID); err == nil {
+				di.URL = streamURL
+			}
+		}
+		items = append(items, di)
+	}
+	return items, nil
+}
+
+// StreamURL resolves id to /Videos/{id}/stream, authenticated the way
+// Jellyfin/Emby accept on a plain GET: an api_key query parameter.
+func (b *embyFamilyBackend) StreamURL(id string) (string, error) {
+	return fmt.Sprintf("%s/Videos/%s/stream?api_key=%s", b.baseURL, id, url.QueryEscape(b.token)), nil
+}
+
+// authenticate attaches the session token to req the way Jellyfin/Emby
+// accept it on any authenticated request.
+func (b *embyFamilyBackend) authenticate(req *http.Request) {
+	req.Header.Set("X-Emby-Authorization", b.authHeader())
+	req.Header.Set("X-Emby-Token", b.token)
+}
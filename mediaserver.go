@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mediaServerDeviceType is the deviceType this process advertises in its
+// device description and answers M-SEARCH for, alongside upnp:rootdevice
+// and contentDirectoryServiceType (defined in contentdirectory.go).
+const mediaServerDeviceType = "urn:schemas-upnp-org:device:MediaServer:1"
+
+// dlnaContentFeatures is the contentFeatures.dlna.org header value sent
+// with every streamed resource: DLNA.ORG_OP=01 advertises byte-range seek
+// support (which http.ServeContent actually implements below), and the
+// flags enable background transfer without committing to a specific
+// DLNA.ORG_PN media profile.
+const dlnaContentFeatures = "DLNA.ORG_OP=01;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// MediaServer turns mop into a DLNA MediaServer:1: it serves the device
+// description, the ContentDirectory SCPD, the ContentDirectory SOAP
+// control endpoint, and byte-range file streaming, all backed by a
+// ContentBackend that maps ObjectIDs to browsable content.
+type MediaServer struct {
+	FriendlyName string
+	UDN          string // "uuid:..." identifying this device instance
+	BaseURL      string // e.g. "http://192.168.1.5:8200", used in the device description and res URLs
+	Backend      ContentBackend
+}
+
+// NewMediaServer creates a MediaServer with a freshly generated UDN.
+func NewMediaServer(friendlyName, baseURL string, backend ContentBackend) *MediaServer {
+	return &MediaServer{
+		FriendlyName: friendlyName,
+		UDN:          "uuid:" + newDeviceUUID(),
+		BaseURL:      baseURL,
+		Backend:      backend,
+	}
+}
+
+// Handler returns the http.Handler serving every endpoint this MediaServer
+// exposes: device description, SCPD, SOAP control, and content streaming.
+func (m *MediaServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", m.serveDeviceDescription)
+	mux.HandleFunc("/cd.xml", m.serveContentDirectorySCPD)
+	mux.HandleFunc("/ctl/ContentDirectory", m.serveSOAPControl)
+	mux.HandleFunc("/content", m.serveContent)
+	return mux
+}
+
+func (m *MediaServer) serveDeviceDescription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	fmt.Fprintf(w, deviceDescriptionXML, escapeXML(m.FriendlyName), m.UDN)
+}
+
+func (m *MediaServer) serveContentDirectorySCPD(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	io.WriteString(w, contentDirectorySCPD)
+}
+
+// serveSOAPControl dispatches Browse/Search/GetSearchCapabilities/
+// GetSortCapabilities, the four ContentDirectory actions this MediaServer
+// implements, and wraps any failure as a SOAP fault carrying a UPnPError.
+func (m *MediaServer) serveSOAPControl(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	action := soapActionName(r.Header.Get("SOAPAction"))
+
+	var responseXML string
+	switch action {
+	case "Browse":
+		responseXML, err = m.handleBrowse(body)
+	case "Search":
+		responseXML, err = m.handleSearch(body)
+	case "GetSearchCapabilities":
+		responseXML = soapResponse(contentDirectoryServiceType, action, `<SearchCaps>dc:title</SearchCaps>`)
+	case "GetSortCapabilities":
+		responseXML = soapResponse(contentDirectoryServiceType, action, `<SortCaps></SortCaps>`)
+	default:
+		err = fmt.Errorf("unsupported action %q", action)
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, soapFaultResponse(701, err.Error()))
+		return
+	}
+	io.WriteString(w, responseXML)
+}
+
+// soapActionName extracts the action name from a SOAPAction header value
+// of the form `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`.
+func soapActionName(header string) string {
+	header = strings.Trim(header, `"`)
+	if idx := strings.LastIndex(header, "#"); idx != -1 {
+		return header[idx+1:]
+	}
+	return header
+}
+
+// browseArgs decodes a <u:Browse> request's arguments directly out of the
+// SOAP envelope; the path tag walks straight to the action element since
+// its name is fixed (unlike the response, which varies by action).
+type browseArgs struct {
+	ObjectID       string `xml:"Body>Browse>ObjectID"`
+	BrowseFlag     string `xml:"Body>Browse>BrowseFlag"`
+	StartingIndex  int    `xml:"Body>Browse>StartingIndex"`
+	RequestedCount int    `xml:"Body>Browse>RequestedCount"`
+}
+
+type searchArgs struct {
+	ContainerID    string `xml:"Body>Search>ContainerID"`
+	SearchCriteria string `xml:"Body>Search>SearchCriteria"`
+	StartingIndex  int    `xml:"Body>Search>StartingIndex"`
+	RequestedCount int    `xml:"Body>Search>RequestedCount"`
+}
+
+func (m *MediaServer) handleBrowse(body []byte) (string, error) {
+	var args browseArgs
+	if err := xml.Unmarshal(body, &args); err != nil {
+		return "", err
+	}
+
+	var objects []ContentObject
+	var err error
+	if browseFlag(args.BrowseFlag) == BrowseMetadata {
+		var obj ContentObject
+		obj, err = m.Backend.Object(args.ObjectID)
+		objects = []ContentObject{obj}
+	} else {
+		objects, err = m.Backend.Children(args.ObjectID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return m.pagedResult("Browse", objects, args.StartingIndex, args.RequestedCount), nil
+}
+
+func (m *MediaServer) handleSearch(body []byte) (string, error) {
+	var args searchArgs
+	if err := xml.Unmarshal(body, &args); err != nil {
+		return "", err
+	}
+
+	objects, err := m.Backend.Search(args.ContainerID, searchTermFromCriteria(args.SearchCriteria))
+	if err != nil {
+		return "", err
+	}
+
+	return m.pagedResult("Search", objects, args.StartingIndex, args.RequestedCount), nil
+}
+
+// searchTermFromCriteria pulls the first quoted value out of a standard
+// UPnP search criteria string like `(dc:title contains "foo")`. mop's own
+// ContentDirectory client (expandSearchQuery in upnp.go) repeats a single
+// term across its dc:title/upnp:artist clauses, so the first quoted value
+// is the whole search term and this doesn't need a full grammar.
+func searchTermFromCriteria(criteria string) string {
+	start := strings.Index(criteria, `"`)
+	if start == -1 {
+		return criteria
+	}
+	end := strings.Index(criteria[start+1:], `"`)
+	if end == -1 {
+		return criteria
+	}
+	return criteria[start+1 : start+1+end]
+}
+
+// pagedResult slices objects to [startingIndex, startingIndex+requestedCount)
+// and wraps the page as a Browse/SearchResponse, the way BrowseDirectory's
+// client side expects to page through StartingIndex/TotalMatches.
+func (m *MediaServer) pagedResult(action string, objects []ContentObject, startingIndex, requestedCount int) string {
+	total := len(objects)
+	if startingIndex < 0 || startingIndex > total {
+		startingIndex = total
+	}
+	end := total
+	if requestedCount > 0 && startingIndex+requestedCount < end {
+		end = startingIndex + requestedCount
+	}
+	page := objects[startingIndex:end]
+
+	inner := fmt.Sprintf(`<Result>%s</Result><NumberReturned>%d</NumberReturned><TotalMatches>%d</TotalMatches><UpdateID>0</UpdateID>`,
+		escapeXML(buildDIDLLite(page, m)), len(page), total)
+	return soapResponse(contentDirectoryServiceType, action, inner)
+}
+
+// serveContent streams the file behind the "id" query parameter, with
+// byte-range support handled by http.ServeContent.
+func (m *MediaServer) serveContent(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	obj, err := m.Backend.Object(id)
+	if err != nil || obj.IsContainer {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := m.Backend.Open(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("contentFeatures.dlna.org", dlnaContentFeatures)
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	http.ServeContent(w, r, obj.Name, time.Time{}, f)
+}
+
+// buildDIDLLite renders objects as a DIDL-Lite document: hand-built XML
+// rather than an encoding/xml Marshal of didlLite, so the dc:/upnp:
+// element prefixes real DLNA control points expect come out literally
+// instead of being stripped by Go's namespace-unaware tag matching.
+func buildDIDLLite(objects []ContentObject, m *MediaServer) string {
+	var b strings.Builder
+	b.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">`)
+
+	for _, obj := range objects {
+		if obj.IsContainer {
+			fmt.Fprintf(&b, `<container id="%s" parentID="%s" restricted="1" searchable="1"><dc:title>%s</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>`,
+				escapeXML(obj.ID), escapeXML(obj.ParentID), escapeXML(obj.Name))
+			continue
+		}
+
+		resURL := fmt.Sprintf("%s/content?id=%s", m.BaseURL, url.QueryEscape(obj.ID))
+		fmt.Fprintf(&b, `<item id="%s" parentID="%s" restricted="1"><dc:title>%s</dc:title><upnp:class>%s</upnp:class><res protocolInfo="%s" size="%d">%s</res></item>`,
+			escapeXML(obj.ID), escapeXML(obj.ParentID), escapeXML(obj.Name), upnpClassFor(obj.MimeType),
+			escapeXML(fmt.Sprintf("http-get:*:%s:%s", obj.MimeType, dlnaContentFeatures)), obj.Size, escapeXML(resURL))
+	}
+
+	b.WriteString(`</DIDL-Lite>`)
+	return b.String()
+}
+
+// upnpClassFor maps a MIME type to the DIDL-Lite upnp:class that tells a
+// control point's UI how to render and filter the item.
+func upnpClassFor(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "video/"):
+		return "object.item.videoItem"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "object.item.audioItem.musicTrack"
+	case strings.HasPrefix(mimeType, "image/"):
+		return "object.item.imageItem.photo"
+	default:
+		return "object.item"
+	}
+}
+
+// newDeviceUUID generates a random RFC 4122 version-4 UUID for UDN.
+func newDeviceUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+const deviceDescriptionXML = `<?xml version="1.0" encoding="utf-8"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaServer:1</deviceType>
+    <friendlyName>%s</friendlyName>
+    <manufacturer>mop</manufacturer>
+    <modelName>mop MediaServer</modelName>
+    <UDN>%s</UDN>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:ContentDirectory:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ContentDirectory</serviceId>
+        <controlURL>/ctl/ContentDirectory</controlURL>
+        <eventSubURL>/evt/ContentDirectory</eventSubURL>
+        <SCPDURL>/cd.xml</SCPDURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
+
+const contentDirectorySCPD = `<?xml version="1.0" encoding="utf-8"?>
+<scpd xmlns="urn:schemas-upnp-org:service-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <actionList>
+    <action>
+      <name>Browse</name>
+      <argumentList>
+        <argument><name>ObjectID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>BrowseFlag</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_BrowseFlag</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>Search</name>
+      <argumentList>
+        <argument><name>ContainerID</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_ObjectID</relatedStateVariable></argument>
+        <argument><name>SearchCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SearchCriteria</relatedStateVariable></argument>
+        <argument><name>Filter</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Filter</relatedStateVariable></argument>
+        <argument><name>StartingIndex</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Index</relatedStateVariable></argument>
+        <argument><name>RequestedCount</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>SortCriteria</name><direction>in</direction><relatedStateVariable>A_ARG_TYPE_SortCriteria</relatedStateVariable></argument>
+        <argument><name>Result</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Result</relatedStateVariable></argument>
+        <argument><name>NumberReturned</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>TotalMatches</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_Count</relatedStateVariable></argument>
+        <argument><name>UpdateID</name><direction>out</direction><relatedStateVariable>A_ARG_TYPE_UpdateID</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSearchCapabilities</name>
+      <argumentList>
+        <argument><name>SearchCaps</name><direction>out</direction><relatedStateVariable>SearchCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+    <action>
+      <name>GetSortCapabilities</name>
+      <argumentList>
+        <argument><name>SortCaps</name><direction>out</direction><relatedStateVariable>SortCapabilities</relatedStateVariable></argument>
+      </argumentList>
+    </action>
+  </actionList>
+  <serviceStateTable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_ObjectID</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Result</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_BrowseFlag</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Filter</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SortCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Index</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_Count</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_UpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>A_ARG_TYPE_SearchCriteria</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="yes"><name>SystemUpdateID</name><dataType>ui4</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SearchCapabilities</name><dataType>string</dataType></stateVariable>
+    <stateVariable sendEvents="no"><name>SortCapabilities</name><dataType>string</dataType></stateVariable>
+  </serviceStateTable>
+</scpd>`
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runMopctl implements the `mop ctl <command> [args...]` companion CLI. It
+// dials the mopd control socket and prints results to stdout so discovery
+// and playback can be scripted from shell pipelines and window-manager
+// keybindings instead of only through the TUI.
+func runMopctl(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mop ctl <list-servers|browse|play|watch-errors> [args...]")
+	}
+
+	client, err := DialControl(defaultSocketPath())
+	if err != nil {
+		return fmt.Errorf("%w\nstart it first with: mop --control-daemon &", err)
+	}
+	defer client.Close()
+
+	switch args[0] {
+	case "list-servers":
+		servers, err := client.ListServers()
+		if err != nil {
+			return err
+		}
+		for _, server := range servers {
+			fmt.Printf("%s\t%s\n", server.Name, server.Location)
+		}
+		return nil
+
+	case "browse":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: mop ctl browse <server> [path]")
+		}
+		path := splitCtlPath(args[2:])
+		items, err := client.Browse(args[1], path)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			kind := "file"
+			if item.IsDirectory {
+				kind = "dir"
+			}
+			fmt.Printf("%s\t%s\n", kind, item.Name)
+		}
+		return nil
+
+	case "play":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: mop ctl play <server> <path>")
+		}
+		path := splitCtlPath(args[2:])
+		return client.Play(args[1], path)
+
+	case "watch-errors":
+		events, err := client.WatchErrors()
+		if err != nil {
+			return err
+		}
+		for event := range events {
+			fmt.Println(event)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown mopctl command: %s", args[0])
+	}
+}
+
+// splitCtlPath accepts either a single "/"-separated path argument or
+// multiple positional segments and normalizes both to a path slice.
+func splitCtlPath(args []string) []string {
+	if len(args) == 1 {
+		trimmed := strings.Trim(args[0], "/")
+		if trimmed == "" {
+			return []string{}
+		}
+		return strings.Split(trimmed, "/")
+	}
+	return args
+}
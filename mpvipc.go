@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const defaultMpvSocketPath = "/tmp/mop-mpv.sock"
+
+// mpvIPCCommand is a single command sent over mpv's --input-ipc-server
+// socket, per mpv's JSON IPC protocol.
+type mpvIPCCommand struct {
+	Command []interface{} `json:"command"`
+}
+
+// MpvSession is a persistent mpv process controlled over a Unix IPC socket.
+// It lets mop append to mpv's own playlist instead of spawning a new
+// detached player per queued file.
+type MpvSession struct {
+	socketPath string
+	conn       net.Conn
+}
+
+// StartMpvSession launches mpv on url with an IPC socket and waits for mpv
+// to create it before returning a handle that can feed it further tracks.
+func StartMpvSession(url string) (*MpvSession, error) {
+	socketPath := defaultMpvSocketPath
+	os.Remove(socketPath)
+
+	cmd := exec.Command("mpv", "--really-quiet", "--no-terminal",
+		fmt.Sprintf("--input-ipc-server=%s", socketPath), url)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mpv: %w", err)
+	}
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 20; i++ {
+		conn, err = net.Dial("unix", socketPath)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mpv did not open its IPC socket: %w", err)
+	}
+
+	return &MpvSession{socketPath: socketPath, conn: conn}, nil
+}
+
+// Append sends "loadfile <url> append" so url joins mpv's playlist after
+// whatever is already queued, advancing to it gaplessly when the current
+// track ends.
+func (s *MpvSession) Append(url string) error {
+	return s.send(mpvIPCCommand{Command: []interface{}{"loadfile", url, "append"}})
+}
+
+func (s *MpvSession) send(cmd mpvIPCCommand) error {
+	return json.NewEncoder(s.conn).Encode(cmd)
+}
+
+// Alive reports whether the IPC socket is still reachable, so callers can
+// fall back to spawning a new detached player if mpv exited.
+func (s *MpvSession) Alive() bool {
+	conn, err := net.Dial("unix", s.socketPath)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (s *MpvSession) Close() error {
+	return s.conn.Close()
+}
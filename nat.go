@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// natDiscoveryTimeout bounds how long DiscoverNAT waits for any of
+// UPnP IGD, NAT-PMP, or PCP to answer before giving up.
+const natDiscoveryTimeout = 3 * time.Second
+
+// NATClient maps this host's local ports to a router's WAN address,
+// mirroring go-ethereum's nat.Interface and syncthing's nat.Device: one
+// small interface in front of whichever of UPnP IGD, NAT-PMP, or PCP the
+// router actually speaks.
+type NATClient interface {
+	// Name identifies the protocol the client is using, for logging.
+	Name() string
+	// AddPortMapping maps externalPort on the router's WAN interface to
+	// internalPort on this host for lifetime, renewing an existing
+	// mapping if one's already held for the same proto/externalPort.
+	AddPortMapping(proto string, internalPort, externalPort int, lifetime time.Duration) error
+	// DeletePortMapping removes a mapping previously created with
+	// AddPortMapping.
+	DeletePortMapping(proto string, externalPort int) error
+	// ExternalIP returns the router's WAN address.
+	ExternalIP() (net.IP, error)
+}
+
+// DiscoverNAT races UPnP IGD, NAT-PMP, and PCP discovery against each
+// other and returns whichever NATClient answers first, per the request's
+// "let the discovery layer prefer whichever protocol responded first".
+func DiscoverNAT() (NATClient, error) {
+	type result struct {
+		client NATClient
+		err    error
+	}
+
+	results := make(chan result, 3)
+	probe := func(discover func(time.Duration) (NATClient, error)) {
+		client, err := discover(natDiscoveryTimeout)
+		results <- result{client, err}
+	}
+
+	go probe(discoverIGD)
+	go probe(func(timeout time.Duration) (NATClient, error) { return NewNATPMPClient(timeout) })
+	go probe(func(timeout time.Duration) (NATClient, error) { return NewPCPClient(timeout) })
+
+	var errs []string
+	for i := 0; i < 3; i++ {
+		r := <-results
+		if r.err == nil {
+			return r.client, nil
+		}
+		errs = append(errs, r.err.Error())
+	}
+
+	return nil, fmt.Errorf("no NAT gateway found (UPnP IGD, NAT-PMP, PCP all failed: %s)", strings.Join(errs, "; "))
+}
+
+// Map is a convenience wrapper around DiscoverNAT for callers that just
+// want a single mapping without holding onto the resolved client.
+func Map(proto string, internalPort, externalPort int, lifetime time.Duration) (NATClient, error) {
+	client, err := DiscoverNAT()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.AddPortMapping(proto, internalPort, externalPort, lifetime); err != nil {
+		return nil, fmt.Errorf("%s: %w", client.Name(), err)
+	}
+	return client, nil
+}
+
+// ExternalIP is a convenience wrapper around DiscoverNAT for callers that
+// just want the router's WAN address.
+func ExternalIP() (net.IP, error) {
+	client, err := DiscoverNAT()
+	if err != nil {
+		return nil, err
+	}
+	return client.ExternalIP()
+}
+
+// defaultGateway guesses the LAN's default gateway as the ".1" address on
+// this host's local /24, the same assumption getLocalNetworkBase makes
+// about the local subnet elsewhere in this package. NAT-PMP and PCP both
+// talk to the default gateway rather than discovering it via multicast.
+func defaultGateway() (net.IP, error) {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	localAddr := conn.LocalAddr().(*net.UDPAddr)
+	ip4 := localAddr.IP.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("no IPv4 outbound address found")
+	}
+
+	gateway := net.IPv4(ip4[0], ip4[1], ip4[2], 1)
+	return gateway, nil
+}
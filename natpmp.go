@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// natPMPPort is the well-known UDP port a NAT-PMP gateway listens on, per
+// RFC 6886 section 3.
+const natPMPPort = 5351
+
+const (
+	natPMPOpExternalAddress = 0
+	natPMPOpMapUDP          = 1
+	natPMPOpMapTCP          = 2
+)
+
+// NATPMPClient is a NATClient speaking NAT-PMP (RFC 6886) to the LAN's
+// default gateway.
+type NATPMPClient struct {
+	gateway net.IP
+	timeout time.Duration
+}
+
+// NewNATPMPClient confirms the default gateway answers NAT-PMP's
+// external-address request within timeout, and returns a client for it.
+func NewNATPMPClient(timeout time.Duration) (*NATPMPClient, error) {
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	client := &NATPMPClient{gateway: gateway, timeout: timeout}
+	if _, err := client.ExternalIP(); err != nil {
+		return nil, fmt.Errorf("NAT-PMP: %w", err)
+	}
+	return client, nil
+}
+
+func (c *NATPMPClient) Name() string { return "NAT-PMP" }
+
+// request sends req to the gateway and returns its reply, retrying with
+// the doubling backoff RFC 6886 section 3.1 recommends (250ms, 500ms,
+// 1s, ...) until one arrives or timeout elapses.
+func (c *NATPMPClient) request(req []byte) ([]byte, error) {
+	conn, err := net.Dial("udp", fmt.Sprintf("%s:%d", c.gateway, natPMPPort))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	resp := make([]byte, 16)
+	for wait := 250 * time.Millisecond; time.Now().Before(deadline); wait *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(resp)
+		if err == nil {
+			return resp[:n], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no NAT-PMP response from %s", c.gateway)
+}
+
+// ExternalIP sends NAT-PMP's external-address request (opcode 0).
+func (c *NATPMPClient) ExternalIP() (net.IP, error) {
+	resp, err := c.request([]byte{0, natPMPOpExternalAddress})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNATPMPResponse(resp, natPMPOpExternalAddress, 12); err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// AddPortMapping sends NAT-PMP's mapping request (opcode 1 for UDP, 2 for
+// TCP) per RFC 6886 section 3.3. lifetime is clamped to whole seconds, as
+// the wire format requires.
+func (c *NATPMPClient) AddPortMapping(proto string, internalPort, externalPort int, lifetime time.Duration) error {
+	op, err := natPMPOpcode(proto)
+	if err != nil {
+		return err
+	}
+
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime.Seconds()))
+
+	resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	return checkNATPMPResponse(resp, op, 16)
+}
+
+// DeletePortMapping asks for a mapping with a zero lifetime, which RFC
+// 6886 section 3.3.1 defines as an explicit deletion request.
+func (c *NATPMPClient) DeletePortMapping(proto string, externalPort int) error {
+	op, err := natPMPOpcode(proto)
+	if err != nil {
+		return err
+	}
+
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+
+	resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	return checkNATPMPResponse(resp, op, 16)
+}
+
+func natPMPOpcode(proto string) (byte, error) {
+	switch strings.ToUpper(proto) {
+	case "UDP":
+		return natPMPOpMapUDP, nil
+	case "TCP":
+		return natPMPOpMapTCP, nil
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q", proto)
+	}
+}
+
+// checkNATPMPResponse validates a NAT-PMP reply's version, opcode (the
+// request's opcode with the response bit 0x80 set), length, and result
+// code, per RFC 6886 section 3.
+func checkNATPMPResponse(resp []byte, requestOp byte, wantLen int) error {
+	if len(resp) < wantLen {
+		return fmt.Errorf("NAT-PMP response too short (%d bytes)", len(resp))
+	}
+	if resp[0] != 0 {
+		return fmt.Errorf("unsupported NAT-PMP version %d", resp[0])
+	}
+	if resp[1] != requestOp|0x80 {
+		return fmt.Errorf("unexpected NAT-PMP opcode 0x%02x", resp[1])
+	}
+	if resultCode := binary.BigEndian.Uint16(resp[2:4]); resultCode != 0 {
+		return fmt.Errorf("NAT-PMP result code %d", resultCode)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// pcpPort is the well-known UDP port a PCP server listens on, per RFC
+// 6887 section 7 (the same port NAT-PMP uses, since PCP supersedes it).
+const pcpPort = 5351
+
+const (
+	pcpVersion  = 2
+	pcpOpMap    = 1
+	pcpProtoTCP = 6
+	pcpProtoUDP = 17
+)
+
+// PCPClient is a NATClient speaking PCP (RFC 6887) to the LAN's default
+// gateway.
+type PCPClient struct {
+	gateway  net.IP
+	internal net.IP
+	timeout  time.Duration
+}
+
+// NewPCPClient confirms the default gateway answers a PCP MAP request
+// within timeout, and returns a client for it.
+func NewPCPClient(timeout time.Duration) (*PCPClient, error) {
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	internal, err := outboundIP()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &PCPClient{gateway: gateway, internal: net.ParseIP(internal), timeout: timeout}
+	if _, err := client.ExternalIP(); err != nil {
+		return nil, fmt.Errorf("PCP: %w", err)
+	}
+	return client, nil
+}
+
+func (c *PCPClient) Name() string { return "PCP" }
+
+// request sends req to the gateway and returns its reply, retrying with
+// the same doubling backoff NATPMPClient.request uses until one arrives
+// or timeout elapses; RFC 6887 section 8.1.1 recommends the same
+// retransmission strategy as NAT-PMP.
+func (c *PCPClient) request(req []byte) ([]byte, error) {
+	conn, err := net.Dial("udp", fmt.Sprintf("%s:%d", c.gateway, pcpPort))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	resp := make([]byte, 1100)
+	for wait := 250 * time.Millisecond; time.Now().Before(deadline); wait *= 2 {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(resp)
+		if err == nil {
+			return resp[:n], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no PCP response from %s", c.gateway)
+}
+
+// mapRequest builds a MAP opcode request (RFC 6887 sections 7.1 and 11):
+// a 24-byte common header followed by the 36-byte MAP-specific payload.
+func (c *PCPClient) mapRequest(proto string, internalPort, externalPort int, lifetime time.Duration) ([]byte, []byte, error) {
+	protoNum, err := pcpProtoNumber(proto)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := make([]byte, 60)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:8], uint32(lifetime.Seconds()))
+	copy(req[8:24], c.internal.To16())
+
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	copy(req[24:36], nonce)
+	req[36] = protoNum
+	binary.BigEndian.PutUint16(req[40:42], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[42:44], uint16(externalPort))
+	// Suggested external address left as 0.0.0.0 (mapped into the
+	// all-zero IPv6 prefix at req[44:60]) to let the server pick one.
+
+	return req, nonce, nil
+}
+
+// ExternalIP learns the router's WAN address the way PCP clients
+// commonly do absent a dedicated query opcode: by issuing a short-lived
+// MAP request and reading the assigned external address back out of the
+// response, per RFC 6887 section 11.
+func (c *PCPClient) ExternalIP() (net.IP, error) {
+	req, nonce, err := c.mapRequest("UDP", 1, 0, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.request(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPCPResponse(resp, pcpOpMap, nonce); err != nil {
+		return nil, err
+	}
+
+	return externalIPFromMapResponse(resp), nil
+}
+
+// AddPortMapping sends a PCP MAP request (RFC 6887 section 11) mapping
+// externalPort to internalPort for lifetime.
+func (c *PCPClient) AddPortMapping(proto string, internalPort, externalPort int, lifetime time.Duration) error {
+	req, nonce, err := c.mapRequest(proto, internalPort, externalPort, lifetime)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	return checkPCPResponse(resp, pcpOpMap, nonce)
+}
+
+// DeletePortMapping asks for a mapping with a zero lifetime, which RFC
+// 6887 section 15 defines as an explicit deletion request.
+func (c *PCPClient) DeletePortMapping(proto string, externalPort int) error {
+	req, nonce, err := c.mapRequest(proto, 0, externalPort, 0)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.request(req)
+	if err != nil {
+		return err
+	}
+	return checkPCPResponse(resp, pcpOpMap, nonce)
+}
+
+func pcpProtoNumber(proto string) (byte, error) {
+	switch strings.ToUpper(proto) {
+	case "UDP":
+		return pcpProtoUDP, nil
+	case "TCP":
+		return pcpProtoTCP, nil
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q", proto)
+	}
+}
+
+// checkPCPResponse validates a PCP reply's version, opcode (the
+// request's opcode with the response bit 0x80 set), nonce echo, and
+// result code, per RFC 6887 sections 7.2 and 11.
+func checkPCPResponse(resp []byte, requestOp byte, nonce []byte) error {
+	if len(resp) < 60 {
+		return fmt.Errorf("PCP response too short (%d bytes)", len(resp))
+	}
+	if resp[0] != pcpVersion {
+		return fmt.Errorf("unsupported PCP version %d", resp[0])
+	}
+	if resp[1] != requestOp|0x80 {
+		return fmt.Errorf("unexpected PCP opcode 0x%02x", resp[1])
+	}
+	if resultCode := resp[3]; resultCode != 0 {
+		return fmt.Errorf("PCP result code %d", resultCode)
+	}
+	for i := range nonce {
+		if resp[24+i] != nonce[i] {
+			return fmt.Errorf("PCP response nonce mismatch")
+		}
+	}
+	return nil
+}
+
+// externalIPFromMapResponse extracts the assigned external address from
+// a MAP response's opcode-specific data, unwrapping an IPv4-mapped IPv6
+// address back to its 4-byte form.
+func externalIPFromMapResponse(resp []byte) net.IP {
+	ip := net.IP(resp[44:60])
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// PlayOpts carries the per-invocation knobs a Player may need beyond the URL
+// itself.
+type PlayOpts struct {
+	// Detach, when true, asks the player to run out of MOP's process tree
+	// instead of blocking in the foreground.
+	Detach bool
+}
+
+// Player is a backend capable of playing a media URL. Built-in backends wrap
+// a local command; future backends (e.g. casting to a UPnP renderer) can
+// satisfy the same interface without touching playSelectedFile.
+type Player interface {
+	Name() string
+	Supports(mime, ext string) bool
+	Play(ctx context.Context, url string, opts PlayOpts) error
+	Detach() bool
+}
+
+// commandPlayer is a Player backed by an external command, optionally
+// restricted to a set of formats it supports.
+type commandPlayer struct {
+	name        string
+	command     string
+	args        []string
+	formats     []string // lowercase mime prefixes or extensions this player handles; empty means "any"
+	detachFlags []string // extra args appended only when detaching
+	canDetach   bool
+}
+
+func (p *commandPlayer) Name() string { return p.name }
+
+func (p *commandPlayer) Detach() bool { return p.canDetach }
+
+func (p *commandPlayer) Supports(mime, ext string) bool {
+	if len(p.formats) == 0 {
+		return true
+	}
+	mime = strings.ToLower(mime)
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	for _, format := range p.formats {
+		if format == ext || strings.HasPrefix(mime, format) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *commandPlayer) Play(ctx context.Context, url string, opts PlayOpts) error {
+	args := append([]string{}, p.args...)
+	if opts.Detach && p.canDetach {
+		args = append(args, p.detachFlags...)
+	}
+	args = append(args, url)
+
+	cmd := exec.CommandContext(ctx, p.command, args...)
+
+	if opts.Detach {
+		shellCmd := fmt.Sprintf("nohup %s > /dev/null 2>&1 &", shellJoin(p.command, args))
+		cmd = exec.CommandContext(ctx, "sh", "-c", shellCmd)
+		return cmd.Run()
+	}
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", p.name, err)
+	}
+	return nil
+}
+
+func shellJoin(command string, args []string) string {
+	parts := []string{command}
+	for _, arg := range args {
+		parts = append(parts, fmt.Sprintf("'%s'", arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// browserPlayer opens a URL with the user's default browser via xdg-open.
+type browserPlayer struct{}
+
+func (browserPlayer) Name() string { return "browser" }
+func (browserPlayer) Detach() bool { return true }
+func (browserPlayer) Supports(mime, ext string) bool {
+	return strings.HasPrefix(strings.ToLower(mime), "image") ||
+		strings.HasPrefix(strings.ToLower(mime), "text")
+}
+func (browserPlayer) Play(ctx context.Context, url string, opts PlayOpts) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", fmt.Sprintf("nohup xdg-open '%s' > /dev/null 2>&1 &", url))
+	return cmd.Run()
+}
+
+// NewBuiltinPlayer constructs one of mop's built-in Player backends by name
+// (mpv, vlc, ffplay, browser). It returns nil for unknown names.
+func NewBuiltinPlayer(name string) Player {
+	switch name {
+	case "mpv":
+		return &commandPlayer{
+			name:        "mpv",
+			command:     "mpv",
+			formats:     []string{"audio", "video", "mp3", "flac", "mp4", "mkv", "webm", "ogg", "wav"},
+			detachFlags: []string{"--really-quiet", "--no-terminal"},
+			canDetach:   true,
+		}
+	case "vlc":
+		return &commandPlayer{
+			name:        "vlc",
+			command:     "vlc",
+			formats:     []string{"audio", "video", "mp3", "flac", "mp4", "mkv", "webm", "ogg", "wav"},
+			detachFlags: []string{"--quiet", "--play-and-exit"},
+			canDetach:   true,
+		}
+	case "ffplay":
+		return &commandPlayer{
+			name:        "ffplay",
+			command:     "ffplay",
+			formats:     []string{"audio", "video", "mp3", "flac", "mp4", "mkv", "webm", "ogg", "wav"},
+			detachFlags: []string{"-autoexit", "-nodisp"},
+			canDetach:   true,
+		}
+	case "browser":
+		return browserPlayer{}
+	default:
+		return nil
+	}
+}
+
+// PlayerRegistry holds the set of configured Player backends and dispatches
+// playback requests to the first one that supports the requested format,
+// falling back to the user's configured default.
+type PlayerRegistry struct {
+	players []Player
+	def     Player
+}
+
+// NewPlayerRegistry builds a registry from MOPConfig: one Player per
+// `[[mop.players]]` entry, plus a fallback default built from the legacy
+// `Run` field (or "mpv" if that is also empty).
+func NewPlayerRegistry(cfg MOPConfig) *PlayerRegistry {
+	reg := &PlayerRegistry{}
+
+	for _, pc := range cfg.Players {
+		player := NewBuiltinPlayer(pc.Name)
+		if cp, ok := player.(*commandPlayer); ok {
+			cp.formats = pc.Formats
+		}
+		if player == nil {
+			player = &commandPlayer{name: pc.Name, command: pc.Command, args: pc.Args, formats: pc.Formats, canDetach: true}
+		}
+		reg.players = append(reg.players, player)
+	}
+
+	defName := cfg.Run
+	if defName == "" {
+		defName = "mpv"
+	}
+	if def := NewBuiltinPlayer(defName); def != nil {
+		reg.def = def
+	} else {
+		reg.def = &commandPlayer{name: defName, command: defName, canDetach: true}
+	}
+
+	return reg
+}
+
+// Select returns the first registered Player that supports the given mime
+// type or extension, falling back to the configured default.
+func (r *PlayerRegistry) Select(mime, ext string) Player {
+	for _, player := range r.players {
+		if player.Supports(mime, ext) {
+			return player
+		}
+	}
+	return r.def
+}
+
+// Play picks a backend for item, matching its file extension and the mime
+// type its DIDL-Lite protocolInfo advertises (if any), and invokes it,
+// detaching unless closeOnRun asks it to run in the foreground.
+func (r *PlayerRegistry) Play(item DirectoryItem, closeOnRun bool) error {
+	if item.URL == "" {
+		return fmt.Errorf("no URL available for this file")
+	}
+
+	mime := ""
+	if item.Metadata != nil && item.Metadata.Format != nil {
+		mime = mimeFromProtocolInfo(*item.Metadata.Format)
+	}
+
+	player := r.Select(mime, filepath.Ext(item.Name))
+	return player.Play(context.Background(), item.URL, PlayOpts{Detach: !closeOnRun})
+}
+
+// mimeFromProtocolInfo extracts the content-format field from a DIDL-Lite
+// protocolInfo string, e.g. "http-get:*:video/mp4:*" -> "video/mp4", the
+// third colon-separated field per the UPnP ConnectionManager spec.
+func mimeFromProtocolInfo(protocolInfo string) string {
+	parts := strings.SplitN(protocolInfo, ":", 4)
+	if len(parts) >= 3 {
+		return parts[2]
+	}
+	return protocolInfo
+}
+
+// Names returns the configured player names plus the built-in default, for
+// settings UI that lets the user pick rather than type a raw command.
+func (r *PlayerRegistry) Names() []string {
+	names := make([]string, 0, len(r.players)+1)
+	for _, player := range r.players {
+		names = append(names, player.Name())
+	}
+	names = append(names, r.def.Name())
+	return names
+}
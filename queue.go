@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// enqueueItem appends item to the playback queue, persists it, and feeds it
+// to the running mpv session (starting one if needed).
+func (a *App) enqueueItem(item DirectoryItem) error {
+	if item.IsDirectory || item.URL == "" {
+		return fmt.Errorf("cannot enqueue %s", item.Name)
+	}
+
+	a.Queue = append(a.Queue, item)
+	a.persistQueue()
+	return a.feedQueueEntry(item)
+}
+
+// insertNextItem inserts item into the queue right after a.Queue[0] rather
+// than at the end. A running mpv session receives it the same way
+// enqueueItem's items do, appended to the end of mpv's own playlist.
+func (a *App) insertNextItem(item DirectoryItem) error {
+	if item.IsDirectory || item.URL == "" {
+		return fmt.Errorf("cannot enqueue %s", item.Name)
+	}
+
+	if len(a.Queue) == 0 {
+		return a.enqueueItem(item)
+	}
+
+	rest := append([]DirectoryItem{item}, a.Queue[1:]...)
+	a.Queue = append(a.Queue[:1:1], rest...)
+	a.persistQueue()
+	return a.feedQueueEntry(item)
+}
+
+// clearQueue empties the queue and tears down the mpv session feeding it.
+func (a *App) clearQueue() {
+	a.Queue = nil
+	if a.mpvSession != nil {
+		a.mpvSession.Close()
+		a.mpvSession = nil
+	}
+	a.persistQueue()
+}
+
+// feedQueueEntry hands item to the running mpv session, starting one if
+// CloseOnRun is false and none exists yet. With CloseOnRun true there is no
+// persistent session to feed; queued items just wait for Enter in the queue
+// view to be played one at a time.
+func (a *App) feedQueueEntry(item DirectoryItem) error {
+	if a.config.MOP.CloseOnRun {
+		return nil
+	}
+
+	if a.mpvSession != nil && a.mpvSession.Alive() {
+		return a.mpvSession.Append(item.URL)
+	}
+
+	session, err := StartMpvSession(item.URL)
+	if err != nil {
+		// Fall back to a one-off detached player for this item.
+		return NewPlayerRegistry(a.config.MOP).Play(item, false)
+	}
+	a.mpvSession = session
+	return nil
+}
+
+func (a *App) persistQueue() {
+	a.config.Queue = a.Queue
+	a.config.Save()
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// deviceState tracks the health of a single discovered device between full
+// SSDP scans, so the background scanner can back off re-probing a device
+// that keeps answering and evict one that stops.
+type deviceState struct {
+	Device    UpnpDevice
+	LastSeen  time.Time
+	Failures  int
+	NextProbe time.Time
+}
+
+// maxProbeFailures is how many consecutive failed HEAD probes a device can
+// accumulate before the scanner evicts it from the server list.
+const maxProbeFailures = 3
+
+// Scan/probe cadence. Full SSDP re-scans back off exponentially between
+// minScanInterval and maxScanInterval when nothing on the network has
+// changed. Per-device HEAD probes run on their own backoff, bounded by
+// minProbeInterval/maxProbeInterval, checked every probeTick.
+const (
+	minScanInterval  = 15 * time.Second
+	maxScanInterval  = 5 * time.Minute
+	minProbeInterval = 10 * time.Second
+	maxProbeInterval = time.Minute
+	probeTick        = 5 * time.Second
+)
+
+// Scanner replaces a one-shot discovery pass with a long-running background
+// scan: full SSDP re-discovery on a backoff schedule, plus cheap per-device
+// HTTP HEAD probes in between so a device that goes dark is evicted well
+// before the next full scan would have noticed.
+type Scanner struct {
+	mu            sync.Mutex
+	devices       map[string]*deviceState // keyed by UpnpDevice.Location
+	discoveryChan chan DiscoveryMessage
+	httpClient    *http.Client
+	rediscover    bool // bypass the on-disk device cache, per mop --rediscover
+}
+
+// NewScanner creates a Scanner that reports discoveries and losses on ch,
+// the same channel the rest of the app already polls via
+// App.checkDiscoveryUpdates. rediscover skips loading the on-disk device
+// cache and forces a cold SSDP/port-scan sweep, per mop --rediscover.
+func NewScanner(ch chan DiscoveryMessage, rediscover bool) *Scanner {
+	return &Scanner{
+		devices:       make(map[string]*deviceState),
+		discoveryChan: ch,
+		httpClient:    &http.Client{Timeout: 3 * time.Second},
+		rediscover:    rediscover,
+	}
+}
+
+// Run alternates full SSDP scans with HEAD probes until ctx is cancelled,
+// alongside a continuous WatchDevices listener that reports devices as
+// they come and go between scans instead of waiting for the next one.
+// It blocks, so callers should invoke it in its own goroutine.
+func (s *Scanner) Run(ctx context.Context) {
+	if !s.rediscover {
+		s.loadFromCache()
+	}
+
+	go s.watch(ctx)
+
+	scanInterval := minScanInterval
+
+	probeTicker := time.NewTicker(probeTick)
+	defer probeTicker.Stop()
+
+	s.scanOnce()
+	scanTimer := time.NewTimer(scanInterval)
+	defer scanTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-probeTicker.C:
+			s.probeDue()
+		case <-scanTimer.C:
+			if s.scanOnce() {
+				scanInterval = minScanInterval
+			} else {
+				scanInterval *= 2
+				if scanInterval > maxScanInterval {
+					scanInterval = maxScanInterval
+				}
+			}
+			scanTimer.Reset(scanInterval)
+		}
+	}
+}
+
+// loadFromCache loads the on-disk device cache, verifies every unexpired
+// entry with a parallel HEAD probe, and reports whichever survive so the
+// UI has a server list immediately instead of waiting out the SSDP sweep
+// Run starts right after. A verified device is deliberately added to
+// s.devices the same way scanOnce's callback does, so the first full scan
+// sees it as already-known and doesn't report it a second time.
+func (s *Scanner) loadFromCache() {
+	entries, err := loadDeviceCache()
+	if err != nil {
+		log.Printf("device cache: load failed: %v", err)
+		return
+	}
+
+	for _, device := range verifyCachedDevices(unexpiredDevices(entries)) {
+		s.deviceFound(device)
+	}
+}
+
+// persistCache snapshots every currently known device to the on-disk
+// cache, so the next launch can skip straight to loadFromCache instead of
+// a cold SSDP sweep.
+func (s *Scanner) persistCache() {
+	s.mu.Lock()
+	entries := make([]deviceCacheEntry, 0, len(s.devices))
+	for _, state := range s.devices {
+		maxAge := state.Device.MaxAge
+		if maxAge <= 0 {
+			maxAge = ssdpDefaultMaxAge
+		}
+		entries = append(entries, deviceCacheEntry{
+			Device:    state.Device,
+			ExpiresAt: time.Now().Add(maxAge),
+		})
+	}
+	s.mu.Unlock()
+
+	if err := saveDeviceCache(entries); err != nil {
+		log.Printf("device cache: save failed: %v", err)
+	}
+}
+
+// scanOnce runs one full SSDP discovery pass, reporting newly found
+// devices and refreshing the health of devices already known. It reports
+// "started"/"completed" around the pass so the UI's discovering indicator
+// keeps working the way it did with the old one-shot startDiscovery. It
+// returns true if the scan surfaced any device not already tracked.
+func (s *Scanner) scanOnce() bool {
+	s.discoveryChan <- DiscoveryMessage{Type: "started"}
+
+	changed := false
+
+	_, errs := DiscoverUpnpDevicesWithCallback(func(device UpnpDevice) {
+		if s.deviceFound(device) {
+			changed = true
+		}
+	})
+
+	for _, err := range errs {
+		s.discoveryChan <- DiscoveryMessage{Type: "error", Error: err}
+	}
+
+	s.discoveryChan <- DiscoveryMessage{Type: "completed"}
+	s.persistCache()
+	return changed
+}
+
+// watch runs WatchDevices for as long as ctx is alive, which is how the
+// scanner notices a new device (or one that announces ssdp:byebye, or
+// lets its ssdp:alive lease lapse) between full re-scans rather than
+// waiting out scanInterval's backoff or a HEAD probe failure streak.
+func (s *Scanner) watch(ctx context.Context) {
+	if err := WatchDevices(ctx, s.deviceFoundCallback, s.deviceLostCallback); err != nil {
+		log.Printf("SSDP watch failed: %v", err)
+	}
+}
+
+// deviceFound records a sighting of device, whether from a full scan or
+// the continuous watcher, returning true the first time it's seen.
+func (s *Scanner) deviceFound(device UpnpDevice) bool {
+	s.mu.Lock()
+	state, known := s.devices[device.Location]
+	if !known {
+		state = &deviceState{NextProbe: time.Now().Add(minProbeInterval)}
+		s.devices[device.Location] = state
+	}
+	state.Device = device
+	state.LastSeen = time.Now()
+	state.Failures = 0
+	s.mu.Unlock()
+
+	if !known {
+		s.discoveryChan <- DiscoveryMessage{Type: "device_found", Device: &device}
+	}
+	return !known
+}
+
+func (s *Scanner) deviceFoundCallback(device UpnpDevice) {
+	s.deviceFound(device)
+}
+
+// deviceLostCallback removes device from the known set and reports it, in
+// response to WatchDevices observing an ssdp:byebye or an expired
+// ssdp:alive lease.
+func (s *Scanner) deviceLostCallback(device UpnpDevice) {
+	s.mu.Lock()
+	_, known := s.devices[device.Location]
+	delete(s.devices, device.Location)
+	s.mu.Unlock()
+
+	if known {
+		s.discoveryChan <- DiscoveryMessage{Type: "device_lost", Device: &device}
+		s.persistCache()
+	}
+}
+
+// probeDue runs a HEAD probe against every known device whose NextProbe
+// has elapsed, pushing NextProbe out further on success (so a quiet,
+// healthy device gets probed less often) and evicting it after
+// maxProbeFailures consecutive failures.
+func (s *Scanner) probeDue() {
+	now := time.Now()
+
+	s.mu.Lock()
+	due := make([]*deviceState, 0, len(s.devices))
+	for _, state := range s.devices {
+		if !state.NextProbe.After(now) {
+			due = append(due, state)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, state := range due {
+		probeURL := state.Device.ContentDirectoryURL
+		if probeURL == "" {
+			probeURL = state.Device.AVTransportURL
+		}
+		if probeURL == "" {
+			continue
+		}
+
+		alive := s.probe(probeURL)
+
+		s.mu.Lock()
+		if alive {
+			// Double the previous probe interval (NextProbe - LastSeen),
+			// clamped to [minProbeInterval, maxProbeInterval].
+			interval := 2 * state.NextProbe.Sub(state.LastSeen)
+			if interval < minProbeInterval {
+				interval = minProbeInterval
+			}
+			if interval > maxProbeInterval {
+				interval = maxProbeInterval
+			}
+			state.LastSeen = time.Now()
+			state.Failures = 0
+			state.NextProbe = time.Now().Add(interval)
+			s.mu.Unlock()
+			continue
+		}
+
+		state.Failures++
+		backoff := minProbeInterval * time.Duration(1<<uint(state.Failures))
+		if backoff > maxProbeInterval {
+			backoff = maxProbeInterval
+		}
+		state.NextProbe = time.Now().Add(backoff)
+		evict := state.Failures >= maxProbeFailures
+		lost := state.Device
+		if evict {
+			delete(s.devices, state.Device.Location)
+		}
+		s.mu.Unlock()
+
+		if evict {
+			s.discoveryChan <- DiscoveryMessage{Type: "device_lost", Device: &lost}
+			s.persistCache()
+		}
+	}
+}
+
+// probe reports whether rawURL answered an HTTP HEAD at all. Any response,
+// even an error status, counts as "alive" — this only checks that the
+// device is still reachable on the network, not that it's healthy.
+func (s *Scanner) probe(rawURL string) bool {
+	req, err := http.NewRequest(http.MethodHead, rawURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
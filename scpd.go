@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// deviceDescription is the root UPnP device description document
+// ("SCPD" in the loose sense used throughout this file — strictly SCPD
+// is the per-service description, but mop only needs the device
+// document's serviceList to resolve control URLs).
+type deviceDescription struct {
+	XMLName xml.Name     `xml:"root"`
+	Device  deviceDetail `xml:"device"`
+}
+
+type deviceDetail struct {
+	DeviceType   string         `xml:"deviceType"`
+	FriendlyName string         `xml:"friendlyName"`
+	Manufacturer string         `xml:"manufacturer"`
+	UDN          string         `xml:"UDN"`
+	ServiceList  []upnpService  `xml:"serviceList>service"`
+	DeviceList   []deviceDetail `xml:"deviceList>device"`
+}
+
+// upnpService is one <service> entry: its type, the SOAP control
+// endpoint, the eventing endpoint, and where its own SCPD document lives.
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+	SCPDURL     string `xml:"SCPDURL"`
+}
+
+// findService returns the first service (searched recursively through
+// any embedded devices) whose serviceType contains nameSubstr, e.g.
+// "ContentDirectory" or "AVTransport".
+func (d deviceDetail) findService(nameSubstr string) (upnpService, bool) {
+	for _, svc := range d.ServiceList {
+		if strings.Contains(svc.ServiceType, nameSubstr) {
+			return svc, true
+		}
+	}
+	for _, child := range d.DeviceList {
+		if svc, ok := child.findService(nameSubstr); ok {
+			return svc, true
+		}
+	}
+	return upnpService{}, false
+}
+
+// fetchDeviceDescription retrieves and parses the device description XML
+// at location (the URL SSDP's LOCATION header points at).
+func fetchDeviceDescription(location string) (*deviceDescription, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var desc deviceDescription
+	if err := xml.Unmarshal(body, &desc); err != nil {
+		return nil, fmt.Errorf("parsing device description: %w", err)
+	}
+	return &desc, nil
+}
+
+// resolveServiceControlURL finds desc's service whose type contains
+// nameSubstr and resolves its controlURL against baseURL, returning ""
+// if no such service was advertised.
+func resolveServiceControlURL(desc *deviceDescription, baseURL, nameSubstr string) string {
+	svc, ok := desc.Device.findService(nameSubstr)
+	if !ok || svc.ControlURL == "" {
+		return ""
+	}
+	if strings.HasPrefix(svc.ControlURL, "http") {
+		return svc.ControlURL
+	}
+	return baseURL + svc.ControlURL
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// searchWorkers bounds how many List calls a recursive search issues
+// concurrently, so a deep tree doesn't open hundreds of simultaneous
+// connections to one source.
+const searchWorkers = 8
+
+// browseCacheCapacity is how many FS.List results a.browseCache keeps,
+// across every search a session runs.
+const browseCacheCapacity = 512
+
+// crossServerSearchPrefix selects runCrossServerSearch instead of the
+// default per-directory walk: a single SOAP ContentDirectory Search
+// issued against every known UPnP server concurrently, rather than a
+// recursive walk of just the currently selected source. Mounts don't
+// speak ContentDirectory, so cross-server search only ever covers
+// a.servers.
+const crossServerSearchPrefix = "all:"
+
+// runSearch walks the subtree (via FS.List, so this works for a mount the
+// same as an UPnP server) rooted at the currently selected source's
+// currentDirectory, streaming each name match back through the existing
+// discoveryChan as a "search_result" message so the UI stays responsive
+// while the walk is still in flight. query is either a case-insensitive
+// substring or, with a "re:" prefix, a Go regexp; with an "all:" prefix it
+// instead runs runCrossServerSearch.
+func (a *App) runSearch(query string) {
+	if query == "" {
+		return
+	}
+	if strings.HasPrefix(query, crossServerSearchPrefix) {
+		a.runCrossServerSearch(strings.TrimPrefix(query, crossServerSearchPrefix))
+		return
+	}
+
+	fs, err := a.currentFS()
+	if err != nil {
+		a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+		a.discoveryChan <- DiscoveryMessage{Type: "search_completed"}
+		return
+	}
+
+	matches, err := newSearchMatcher(query)
+	if err != nil {
+		a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+		a.discoveryChan <- DiscoveryMessage{Type: "search_completed"}
+		return
+	}
+
+	a.searching = true
+	a.searchResults = nil
+	a.searchSelectedItem = -1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.searchCancel = cancel
+
+	rootPath := append([]string{}, a.currentDirectory...)
+	serverIndex := a.selectedServer
+	serverName := a.serverDisplayName(serverIndex)
+
+	sem := make(chan struct{}, searchWorkers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go a.walkSearch(ctx, sem, &wg, fs, rootPath, serverIndex, serverName, matches)
+
+	go func() {
+		wg.Wait()
+		a.discoveryChan <- DiscoveryMessage{Type: "search_completed"}
+	}()
+}
+
+// runCrossServerSearch issues a ContentDirectory Search against every
+// known UPnP server concurrently, streaming matches back through the same
+// discoveryChan as runSearch's per-directory walk. This is chunk0-5's
+// original cross-server search, reached via the "all:" query prefix since
+// runSearch's default became a single-source recursive walk. Unlike that
+// walk it has no single directory to recurse into and nothing to cancel
+// mid-flight, so it leaves a.searchCancel nil.
+func (a *App) runCrossServerSearch(query string) {
+	if query == "" {
+		return
+	}
+
+	criteria := expandSearchQuery(query)
+	a.searching = true
+	a.searchResults = nil
+	a.searchSelectedItem = -1
+	a.searchCancel = nil
+
+	servers := append([]UpnpDevice{}, a.servers...)
+
+	go func() {
+		var wg sync.WaitGroup
+		for i, server := range servers {
+			if server.ContentDirectoryURL == "" {
+				continue
+			}
+			wg.Add(1)
+			go func(serverIndex int, server UpnpDevice) {
+				defer wg.Done()
+				cds, err := NewContentDirectoryClient(&server)
+				if err != nil {
+					a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+					return
+				}
+				items, err := cds.Search("0", criteria)
+				if err != nil {
+					a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+					return
+				}
+				for _, item := range items {
+					a.discoveryChan <- DiscoveryMessage{
+						Type:         "search_result",
+						SearchResult: &SearchResult{Item: item, ServerIndex: serverIndex, ServerName: server.Name},
+					}
+				}
+			}(i, server)
+		}
+		wg.Wait()
+		a.discoveryChan <- DiscoveryMessage{Type: "search_completed"}
+	}()
+}
+
+// walkSearch lists path on fs, reports every child whose name matches,
+// and recurses into child directories, bounded to searchWorkers
+// concurrent List calls via sem. It returns once ctx is cancelled (the
+// "esc" key in StateSearch) or the subtree is exhausted.
+func (a *App) walkSearch(ctx context.Context, sem chan struct{}, wg *sync.WaitGroup, fs FS, path []string, serverIndex int, serverName string, matches func(string) bool) {
+	defer wg.Done()
+
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	items, err := a.browseCached(fs, strings.Join(path, "/"))
+	if err != nil {
+		a.discoveryChan <- DiscoveryMessage{Type: "error", Error: err.Error()}
+		return
+	}
+
+	for _, item := range items {
+		if matches(item.Name) {
+			a.discoveryChan <- DiscoveryMessage{
+				Type: "search_result",
+				SearchResult: &SearchResult{
+					Item:        item,
+					Path:        append([]string{}, path...),
+					ServerIndex: serverIndex,
+					ServerName:  serverName,
+				},
+			}
+		}
+		if item.IsDirectory {
+			wg.Add(1)
+			childPath := append(append([]string{}, path...), item.Name)
+			go a.walkSearch(ctx, sem, wg, fs, childPath, serverIndex, serverName, matches)
+		}
+	}
+}
+
+// browseCached lists p on fs, serving the result from a.browseCache when
+// this search (or an earlier one) already visited it.
+func (a *App) browseCached(fs FS, p string) ([]DirectoryItem, error) {
+	key := fs.URI() + "#" + p
+	if items, ok := a.browseCache.get(key); ok {
+		return items, nil
+	}
+
+	entries, err := fs.List(context.Background(), p)
+	if err != nil {
+		return nil, err
+	}
+	items := entriesToDirectoryItems(fs, entries)
+	a.browseCache.put(key, items)
+	return items, nil
+}
+
+// newSearchMatcher builds a name-matching predicate from query: a "re:"
+// prefix selects a Go regexp (matched as written, not lowercased);
+// anything else is a case-insensitive substring match.
+func newSearchMatcher(query string) (func(name string) bool, error) {
+	if strings.HasPrefix(query, "re:") {
+		re, err := regexp.Compile(strings.TrimPrefix(query, "re:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	needle := strings.ToLower(query)
+	return func(name string) bool {
+		return strings.Contains(strings.ToLower(name), needle)
+	}, nil
+}
+
+// lruCache is a small fixed-capacity LRU cache of FS.List results, keyed
+// by "FS.URI()#path", so walkSearch re-running over the same subtree (a
+// repeated search, or backing out and in again) doesn't re-issue the
+// underlying Browse/PROPFIND/ReadDir calls.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []string
+	entries  map[string][]DirectoryItem
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{capacity: capacity, entries: make(map[string][]DirectoryItem)}
+}
+
+func (c *lruCache) get(key string) ([]DirectoryItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items, ok := c.entries[key]
+	if ok {
+		c.touch(key)
+	}
+	return items, ok
+}
+
+func (c *lruCache) put(key string, items []DirectoryItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists {
+		c.order = append(c.order, key)
+	} else {
+		c.touch(key)
+	}
+	c.entries[key] = items
+
+	for len(c.order) > c.capacity {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+}
+
+// touch moves key to the most-recently-used end of c.order. Caller holds
+// c.mu.
+func (c *lruCache) touch(key string) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
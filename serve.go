@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+// runServe implements `mop serve [flags]`: it turns this process into a
+// DLNA MediaServer:1, announcing itself over SSDP and serving a local
+// directory tree over HTTP, until interrupted.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory to serve as a DLNA MediaServer")
+	port := fs.Int("port", 8200, "HTTP port to serve the device description and content on")
+	name := fs.String("name", "mop MediaServer", "friendly name announced to DLNA control points")
+	forward := fs.Bool("forward", false, "publish the server through the router via UPnP IGD/NAT-PMP/PCP port mapping")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	absDir, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+
+	ip, err := outboundIP()
+	if err != nil {
+		return fmt.Errorf("determining local address to announce: %w", err)
+	}
+	baseURL := fmt.Sprintf("http://%s:%d", ip, *port)
+
+	server := NewMediaServer(*name, baseURL, NewLocalFSBackend(absDir))
+
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", *port), Handler: server.Handler()}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("mop serve: HTTP server error: %v\n", err)
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if *forward {
+		go renewPortMapping(ctx, *port)
+	}
+
+	responder := NewSSDPResponder(server)
+	go func() {
+		if err := responder.Run(ctx); err != nil {
+			fmt.Printf("mop serve: SSDP responder error: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("mop serve: serving %s as %q at %s\n", absDir, *name, baseURL)
+	<-ctx.Done()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// portMappingLifetime is how long a single port mapping is requested
+// for; renewPortMapping refreshes it well before it expires.
+const portMappingLifetime = 30 * time.Minute
+
+// renewPortMapping publishes port through the LAN's NAT gateway via
+// whichever of UPnP IGD/NAT-PMP/PCP answers DiscoverNAT first, and
+// refreshes the mapping at half its lifetime for as long as ctx is live,
+// mirroring syncthing's renewUPnP.
+func renewPortMapping(ctx context.Context, port int) {
+	client, err := Map("TCP", port, port, portMappingLifetime)
+	if err != nil {
+		fmt.Printf("mop serve: port mapping failed: %v\n", err)
+		return
+	}
+	fmt.Printf("mop serve: published port %d via %s\n", port, client.Name())
+
+	ticker := time.NewTicker(portMappingLifetime / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			client.DeletePortMapping("TCP", port)
+			return
+		case <-ticker.C:
+			if err := client.AddPortMapping("TCP", port, port, portMappingLifetime); err != nil {
+				fmt.Printf("mop serve: renewing port mapping: %v\n", err)
+			}
+		}
+	}
+}
+
+// outboundIP returns the local address used to reach the network's
+// default route, i.e. the address a remote control point would use to
+// reach this machine. It mirrors getLocalNetworkBase's approach in
+// upnp.go, but keeps the full address rather than just the /24 prefix.
+func outboundIP() (string, error) {
+	conn, err := net.Dial("udp", "8.8.8.8:80")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
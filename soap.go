@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// soapEnvelope is the standard SOAP 1.1 envelope, used to decode whatever
+// a device sends back; outgoing requests are built as text by soapRequest.
+type soapEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    soapBody `xml:"Body"`
+}
+
+type soapBody struct {
+	Fault *upnpFault `xml:"Fault"`
+	// Inner holds the body's contents verbatim so the caller can decode
+	// the action-specific response element itself (its name depends on
+	// the action, so it can't be matched by a fixed struct tag here).
+	Inner []byte `xml:",innerxml"`
+}
+
+// upnpFault is a SOAP fault carrying the UPnP-specific error code and
+// description nested in detail>UPnPError, per the UPnP Device
+// Architecture spec (section on "Control: Errors").
+type upnpFault struct {
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	Detail      struct {
+		UPnPError struct {
+			ErrorCode        int    `xml:"errorCode"`
+			ErrorDescription string `xml:"errorDescription"`
+		} `xml:"UPnPError"`
+	} `xml:"detail"`
+}
+
+func (f *upnpFault) Error() string {
+	if f.Detail.UPnPError.ErrorCode != 0 {
+		return fmt.Sprintf("UPnP error %d: %s", f.Detail.UPnPError.ErrorCode, f.Detail.UPnPError.ErrorDescription)
+	}
+	return fmt.Sprintf("SOAP fault %s: %s", f.FaultCode, f.FaultString)
+}
+
+// soapRequest builds the envelope for a single action call: serviceType
+// identifies the urn (e.g. "urn:schemas-upnp-org:service:ContentDirectory:1"),
+// action is the element/method name, and argsXML is the already-escaped
+// body of argument elements.
+func soapRequest(serviceType, action, argsXML string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:%s xmlns:u="%s">
+            %s
+        </u:%s>
+    </s:Body>
+</s:Envelope>`, action, serviceType, argsXML, action)
+}
+
+// soapCall POSTs a SOAP action to controlURL and returns the raw response
+// body. It decodes the envelope enough to detect a Fault and surface its
+// UPnPError code/description as the returned error rather than making the
+// caller grep the body for "Fault".
+func soapCall(client *http.Client, controlURL, serviceType, action, argsXML string) ([]byte, error) {
+	req, err := http.NewRequest("POST", controlURL, strings.NewReader(soapRequest(serviceType, action, argsXML)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", fmt.Sprintf("%q", serviceType+"#"+action))
+	req.Header.Set("User-Agent", "MOP/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope soapEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%s: decoding SOAP envelope: %w", action, err)
+	}
+	if envelope.Body.Fault != nil {
+		return nil, fmt.Errorf("%s: %w", action, envelope.Body.Fault)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected HTTP status %d", action, resp.StatusCode)
+	}
+
+	return envelope.Body.Inner, nil
+}
+
+// defaultSOAPTimeout matches the per-request timeout the ad-hoc SOAP calls
+// in this package used before they were consolidated into soapCall.
+const defaultSOAPTimeout = 10 * time.Second
+
+// soapResponse builds the envelope for a successful action reply: the
+// response element is named "<action>Response" per the UPnP spec, and
+// innerXML is the already-built argument elements. This is the MediaServer
+// mode's counterpart to soapRequest.
+func soapResponse(serviceType, action, innerXML string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:%sResponse xmlns:u="%s">
+            %s
+        </u:%sResponse>
+    </s:Body>
+</s:Envelope>`, action, serviceType, innerXML, action)
+}
+
+// soapFaultResponse builds a SOAP fault envelope carrying a UPnPError, for
+// reporting a device-side failure (e.g. "no such object") back to a
+// control point in the shape it expects.
+func soapFaultResponse(errorCode int, description string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <s:Fault>
+            <faultcode>s:Client</faultcode>
+            <faultstring>UPnPError</faultstring>
+            <detail>
+                <UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+                    <errorCode>%d</errorCode>
+                    <errorDescription>%s</errorDescription>
+                </UPnPError>
+            </detail>
+        </s:Fault>
+    </s:Body>
+</s:Envelope>`, errorCode, escapeXML(description))
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// ssdpGroupAddr is the SSDP multicast group every interface joins to send
+// M-SEARCH requests and receive NOTIFY announcements.
+var ssdpGroupAddr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+
+// ssdpSearchTargets are the ST values mop searches for on every interface:
+// plain root devices plus the two device types mop actually knows how to
+// talk to.
+var ssdpSearchTargets = []string{
+	"upnp:rootdevice",
+	"urn:schemas-upnp-org:device:MediaServer:1",
+	"urn:schemas-upnp-org:device:MediaRenderer:1",
+}
+
+// ssdpSearchMX is the MX header mop advertises in its M-SEARCH requests:
+// the window, in seconds, a responding device should wait a random amount
+// of time within before replying, so every device on the network doesn't
+// answer at once.
+const ssdpSearchMX = 3
+
+// ssdpSearchAttempts is how many times mop repeats the full M-SEARCH burst
+// per interface, the way goupnp and syncthing do, since UDP multicast
+// datagrams are routinely dropped.
+const ssdpSearchAttempts = 3
+
+// ssdpSearchSpacing is the delay between successive M-SEARCH bursts.
+const ssdpSearchSpacing = 1 * time.Second
+
+// ssdpDiscoveryWindow is how long a bounded DiscoverUpnpDevices* call
+// listens for responses and passing NOTIFYs, counted from the start of the
+// pass; each interface's M-SEARCH bursts are sent within this window.
+const ssdpDiscoveryWindow = 5 * time.Second
+
+// ssdpDefaultMaxAge is the lease WatchDevices assumes for an ssdp:alive
+// announcement that didn't carry a CACHE-CONTROL max-age, mirroring the
+// max-age mop's own MediaServer advertises (see ssdpMaxAge).
+const ssdpDefaultMaxAge = ssdpMaxAge * time.Second
+
+// multicastInterfaces returns the network interfaces SSDP discovery should
+// fan out over: up, multicast-capable, not loopback, and carrying at least
+// one IPv4 address. This is what lets mop find devices that only answer on
+// a VPN, docker, or secondary wifi/eth interface instead of whichever one
+// the OS happens to pick for a wildcard socket.
+func multicastInterfaces() []net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var out []net.Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				out = append(out, iface)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ssdpListener owns one interface's multicast group membership: it can
+// send the M-SEARCH retry burst and/or passively collect NOTIFY
+// announcements arriving on the same socket.
+type ssdpListener struct {
+	iface net.Interface
+	conn  net.PacketConn
+}
+
+// ssdpListenConfig sets SO_REUSEADDR and SO_REUSEPORT on every socket
+// newSSDPListener opens, so each multicast-capable interface can bind its
+// own listener to the same :1900 port instead of all but the first one
+// failing with "address already in use".
+var ssdpListenConfig = net.ListenConfig{
+	Control: func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		if err := c.Control(func(fd uintptr) {
+			if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+				sockErr = err
+				return
+			}
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+		}); err != nil {
+			return err
+		}
+		return sockErr
+	},
+}
+
+// newSSDPListener binds a UDP4 socket to the SSDP port and joins
+// ssdpGroupAddr on iface via golang.org/x/net/ipv4, so the socket
+// receives both unicast M-SEARCH responses and multicast NOTIFY traffic
+// on that interface, and sends its own multicast M-SEARCHes out of that
+// interface. The socket is opened with SO_REUSEADDR/SO_REUSEPORT
+// (see ssdpListenConfig) so every interface can share port 1900 instead of
+// only the first caller winning the bind.
+func newSSDPListener(iface net.Interface) (*ssdpListener, error) {
+	conn, err := ssdpListenConfig.ListenPacket(context.Background(), "udp4", ":1900")
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", iface.Name, err)
+	}
+
+	pconn := ipv4.NewPacketConn(conn)
+	if err := pconn.JoinGroup(&iface, ssdpGroupAddr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("joining multicast group on %s: %w", iface.Name, err)
+	}
+	if err := pconn.SetMulticastInterface(&iface); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("selecting multicast interface %s: %w", iface.Name, err)
+	}
+
+	return &ssdpListener{iface: iface, conn: conn}, nil
+}
+
+func (l *ssdpListener) Close() error {
+	return l.conn.Close()
+}
+
+// search sends ssdpSearchAttempts M-SEARCH bursts, ssdpSearchSpacing
+// apart, one request per ssdpSearchTargets entry per burst.
+func (l *ssdpListener) search() {
+	for attempt := 0; attempt < ssdpSearchAttempts; attempt++ {
+		for _, st := range ssdpSearchTargets {
+			request := "M-SEARCH * HTTP/1.1\r\n" +
+				"HOST: 239.255.255.250:1900\r\n" +
+				"MAN: \"ssdp:discover\"\r\n" +
+				"ST: " + st + "\r\n" +
+				"MX: " + strconv.Itoa(ssdpSearchMX) + "\r\n\r\n"
+			if _, err := l.conn.WriteTo([]byte(request), ssdpGroupAddr); err != nil {
+				log.Printf("SSDP search on %s failed: %v", l.iface.Name, err)
+			}
+		}
+		if attempt < ssdpSearchAttempts-1 {
+			time.Sleep(ssdpSearchSpacing)
+		}
+	}
+}
+
+// run reads both M-SEARCH responses and NOTIFY announcements, handing the
+// raw datagram to handle, until ctx is cancelled.
+func (l *ssdpListener) run(ctx context.Context, handle func(message string)) {
+	go func() {
+		<-ctx.Done()
+		l.conn.Close()
+	}()
+
+	buffer := make([]byte, 4096)
+	for {
+		l.conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := l.conn.ReadFrom(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		handle(string(buffer[:n]))
+	}
+}
+
+// DiscoverUpnpDevices runs one bounded SSDP discovery pass across every
+// multicast-capable interface, falling back to port scanning for devices
+// that don't speak SSDP.
+func DiscoverUpnpDevices() ([]UpnpDevice, []string) {
+	return DiscoverUpnpDevicesWithCallback(func(UpnpDevice) {})
+}
+
+// DiscoverUpnpDevicesWithCallback is DiscoverUpnpDevices, but also invokes
+// callback the first time each device is seen, for callers that want to
+// react as results arrive instead of waiting for the whole pass.
+func DiscoverUpnpDevicesWithCallback(callback func(UpnpDevice)) ([]UpnpDevice, []string) {
+	var devices []UpnpDevice
+	var errors []string
+
+	setupLogging()
+	log.Println("Starting UPnP discovery...")
+
+	ssdpDevices, ssdpErrors := discoverViaSSDPWithCallback(callback)
+	log.Printf("SSDP found %d devices, %d errors\n", len(ssdpDevices), len(ssdpErrors))
+	devices = append(devices, ssdpDevices...)
+	errors = append(errors, ssdpErrors...)
+
+	portDevices, portErrors := discoverViaPortScanWithCallback(callback)
+	log.Printf("Port scan found %d devices, %d errors\n", len(portDevices), len(portErrors))
+	for _, device := range portDevices {
+		found := false
+		for _, existing := range devices {
+			if existing.Location == device.Location {
+				found = true
+				break
+			}
+		}
+		if !found {
+			devices = append(devices, device)
+		}
+	}
+	errors = append(errors, portErrors...)
+
+	log.Printf("Total devices found: %d\n", len(devices))
+	return devices, errors
+}
+
+// discoverViaSSDP is discoverViaSSDPWithCallback with a no-op callback.
+func discoverViaSSDP() ([]UpnpDevice, []string) {
+	return discoverViaSSDPWithCallback(func(UpnpDevice) {})
+}
+
+// discoverViaSSDPWithCallback fans SSDP discovery out over every
+// multicast-capable interface: each gets its own goroutine that joins the
+// multicast group, sends the M-SEARCH retry burst, and collects both
+// M-SEARCH responses and any NOTIFY ssdp:alive announcements that arrive
+// during ssdpDiscoveryWindow. callback fires the first time each device
+// (keyed by Location) is seen, on whichever interface saw it first.
+func discoverViaSSDPWithCallback(callback func(UpnpDevice)) ([]UpnpDevice, []string) {
+	ifaces := multicastInterfaces()
+	if len(ifaces) == 0 {
+		return nil, []string{"no multicast-capable network interfaces found"}
+	}
+
+	var (
+		mu      sync.Mutex
+		devices []UpnpDevice
+		seen    = make(map[string]bool)
+		errs    []string
+	)
+
+	report := func(device UpnpDevice) {
+		mu.Lock()
+		if seen[device.Location] {
+			mu.Unlock()
+			return
+		}
+		seen[device.Location] = true
+		devices = append(devices, device)
+		mu.Unlock()
+		callback(device)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ssdpDiscoveryWindow)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, iface := range ifaces {
+		iface := iface
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			listener, err := newSSDPListener(iface)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
+				return
+			}
+			defer listener.Close()
+
+			go listener.search()
+			listener.run(ctx, func(message string) {
+				if device := parseSSDPMessage(message); device != nil {
+					report(*device)
+				}
+			})
+		}()
+	}
+	wg.Wait()
+
+	return devices, errs
+}
+
+// WatchDevices runs SSDP discovery continuously until ctx is cancelled:
+// like discoverViaSSDPWithCallback it fans one goroutine per
+// multicast-capable interface to send the initial M-SEARCH retry burst,
+// but the goroutines then keep listening indefinitely instead of stopping
+// after ssdpDiscoveryWindow. onFound fires the first time a device is
+// seen (via M-SEARCH response or ssdp:alive); onLost fires when a device
+// sends ssdp:byebye, or when its most recent ssdp:alive's CACHE-CONTROL
+// max-age elapses without a refresh, so a device that vanishes ungracefully
+// is still noticed. WatchDevices blocks until ctx is cancelled, so callers
+// should run it in its own goroutine.
+func WatchDevices(ctx context.Context, onFound, onLost func(UpnpDevice)) error {
+	ifaces := multicastInterfaces()
+	if len(ifaces) == 0 {
+		return fmt.Errorf("no multicast-capable network interfaces found")
+	}
+
+	var mu sync.Mutex
+	leases := make(map[string]*ssdpLease) // keyed by Location
+
+	found := func(device UpnpDevice, maxAge time.Duration) {
+		mu.Lock()
+		lease, known := leases[device.Location]
+		if known {
+			lease.device = device
+			lease.timer.Reset(maxAge)
+		} else {
+			lease = &ssdpLease{device: device}
+			lease.timer = time.AfterFunc(maxAge, func() {
+				mu.Lock()
+				delete(leases, device.Location)
+				mu.Unlock()
+				onLost(device)
+			})
+			leases[device.Location] = lease
+		}
+		mu.Unlock()
+
+		if !known {
+			onFound(device)
+		}
+	}
+
+	lost := func(location string) {
+		mu.Lock()
+		lease, known := leases[location]
+		if known {
+			lease.timer.Stop()
+			delete(leases, location)
+		}
+		mu.Unlock()
+
+		if known {
+			onLost(lease.device)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, iface := range ifaces {
+		iface := iface
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			listener, err := newSSDPListener(iface)
+			if err != nil {
+				log.Printf("SSDP watch: %v", err)
+				return
+			}
+			defer listener.Close()
+
+			go listener.search()
+			listener.run(ctx, func(message string) {
+				if strings.HasPrefix(message, "HTTP/1.1 200 OK") {
+					if device := parseSSDPResponse(message); device != nil {
+						found(*device, ssdpDefaultMaxAge)
+					}
+					return
+				}
+
+				notify := parseSSDPNotify(message)
+				if notify == nil {
+					return
+				}
+				if notify.nts == "ssdp:byebye" {
+					lost(notify.location)
+					return
+				}
+				if device := buildDevice(notify.location, "", notify.nt, notify.usn, notify.maxAge); device != nil {
+					found(*device, notify.maxAge)
+				}
+			})
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// ssdpLease pairs a device WatchDevices has seen with the timer counting
+// down its current ssdp:alive lease; the timer is reset on every refresh
+// and fires onLost if it ever elapses.
+type ssdpLease struct {
+	device UpnpDevice
+	timer  *time.Timer
+}
+
+// ssdpNotify is one parsed `NOTIFY * HTTP/1.1` multicast announcement.
+type ssdpNotify struct {
+	nt       string
+	nts      string // "ssdp:alive" or "ssdp:byebye"
+	usn      string
+	location string
+	maxAge   time.Duration
+}
+
+// parseSSDPNotify parses a NOTIFY announcement, returning nil if message
+// isn't one or is missing the NT/USN headers every real announcement
+// carries.
+func parseSSDPNotify(message string) *ssdpNotify {
+	if !strings.HasPrefix(message, "NOTIFY") {
+		return nil
+	}
+
+	notify := &ssdpNotify{maxAge: ssdpDefaultMaxAge}
+	for _, line := range strings.Split(message, "\r\n") {
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		header := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+
+		switch header {
+		case "nt":
+			notify.nt = value
+		case "nts":
+			notify.nts = value
+		case "usn":
+			notify.usn = value
+		case "location":
+			notify.location = value
+		case "cache-control":
+			if maxAge, ok := parseMaxAge(value); ok {
+				notify.maxAge = maxAge
+			}
+		}
+	}
+
+	if notify.nt == "" || notify.usn == "" {
+		return nil
+	}
+	return notify
+}
+
+// parseMaxAge extracts the seconds value out of a `max-age=N`
+// CACHE-CONTROL header.
+func parseMaxAge(cacheControl string) (time.Duration, bool) {
+	const prefix = "max-age="
+	lower := strings.ToLower(cacheControl)
+	if !strings.HasPrefix(lower, prefix) {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(lower[len(prefix):])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// parseSSDPMessage parses either an M-SEARCH "HTTP/1.1 200 OK" response or
+// a NOTIFY ssdp:alive announcement into a UpnpDevice. It returns nil for
+// anything else, including ssdp:byebye, which a bounded discovery pass has
+// no tracked device to evict anyway.
+func parseSSDPMessage(message string) *UpnpDevice {
+	if strings.HasPrefix(message, "HTTP/1.1 200 OK") {
+		return parseSSDPResponse(message)
+	}
+	if notify := parseSSDPNotify(message); notify != nil && notify.nts == "ssdp:alive" {
+		return buildDevice(notify.location, "", notify.nt, notify.usn, notify.maxAge)
+	}
+	return nil
+}
+
+// parseSSDPResponse parses an M-SEARCH "HTTP/1.1 200 OK" response into a
+// UpnpDevice, or nil if it isn't one or carries no LOCATION.
+func parseSSDPResponse(response string) *UpnpDevice {
+	if !strings.HasPrefix(response, "HTTP/1.1 200 OK") {
+		return nil
+	}
+
+	var location, server, st, usn string
+	maxAge := ssdpDefaultMaxAge
+	for _, line := range strings.Split(response, "\r\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		colonIndex := strings.Index(line, ":")
+		if colonIndex == -1 {
+			continue
+		}
+
+		header := strings.ToLower(strings.TrimSpace(line[:colonIndex]))
+		value := strings.TrimSpace(line[colonIndex+1:])
+
+		switch header {
+		case "location":
+			location = value
+		case "server":
+			server = value
+		case "st":
+			st = value
+		case "usn":
+			usn = value
+		case "cache-control":
+			if parsed, ok := parseMaxAge(value); ok {
+				maxAge = parsed
+			}
+		}
+	}
+
+	if location == "" {
+		return nil
+	}
+
+	return buildDevice(location, server, st, usn, maxAge)
+}
+
+// buildDevice resolves location's service control URLs and assembles a
+// UpnpDevice from headers shared by M-SEARCH responses (which carry a
+// SERVER header) and NOTIFY announcements (whose SERVER header
+// parseSSDPNotify doesn't read, so server is "" and friendly-name
+// detection falls back to deviceType/usn). maxAge is the
+// device's CACHE-CONTROL lease length, used by devicecache.go to expire a
+// stale cached sighting.
+func buildDevice(location, server, deviceType, usn string, maxAge time.Duration) *UpnpDevice {
+	if deviceType == "" {
+		deviceType = "Unknown"
+	}
+
+	manufacturer := server
+	if manufacturer == "" {
+		manufacturer = "Unknown"
+	}
+
+	friendlyName := extractFriendlyName(server, usn, deviceType)
+	displayName := friendlyName
+	if manufacturer != "Unknown" {
+		displayName = fmt.Sprintf("%s (%s)", friendlyName, manufacturer)
+	}
+
+	baseURL := extractBaseURL(location)
+	contentDirURL, avTransportURL := resolveKnownServiceURLs(location, baseURL)
+
+	deviceClient := manufacturer
+	if backendType := mediaBackendDeviceClient(server); backendType != "" {
+		deviceClient = backendType
+	}
+
+	return &UpnpDevice{
+		Name:                displayName,
+		Location:            location,
+		BaseURL:             baseURL,
+		DeviceClient:        deviceClient,
+		ContentDirectoryURL: contentDirURL,
+		AVTransportURL:      avTransportURL,
+		DeviceType:          deviceType,
+		MaxAge:              maxAge,
+	}
+}
+
+// mediaBackendDeviceClient recognizes the SERVER header of a server this
+// build has a MediaBackend for, returning the DeviceClient tag
+// BrowseDirectory dispatches on ("" if server doesn't match any of
+// them, in which case DeviceClient falls back to the raw manufacturer
+// string).
+func mediaBackendDeviceClient(server string) string {
+	lower := strings.ToLower(server)
+	switch {
+	case strings.Contains(lower, "plex") || strings.Contains(lower, "platinum"):
+		return "Plex"
+	case strings.Contains(lower, "jellyfin"):
+		return "Jellyfin"
+	case strings.Contains(lower, "emby"):
+		return "Emby"
+	default:
+		return ""
+	}
+}
+
+func extractFriendlyName(server, usn, deviceType string) string {
+	if server != "" {
+		if strings.Contains(strings.ToLower(server), "plex") || strings.Contains(strings.ToLower(server), "platinum") {
+			return "Plex Media Server"
+		}
+		if strings.Contains(strings.ToLower(server), "jellyfin") {
+			return "Jellyfin Server"
+		}
+		if strings.Contains(strings.ToLower(server), "emby") {
+			return "Emby Server"
+		}
+		if strings.Contains(strings.ToLower(server), "sonos") {
+			return "Sonos Speaker"
+		}
+		if strings.Contains(strings.ToLower(server), "chromecast") {
+			return "Chromecast"
+		}
+		if strings.Contains(strings.ToLower(server), "hue") {
+			return "Philips Hue Bridge"
+		}
+		if strings.Contains(strings.ToLower(server), "hp-ilo") {
+			return "HP iLO Server"
+		}
+	}
+
+	if usn != "" {
+		if strings.Contains(usn, "RINCON_") {
+			return "Sonos Speaker"
+		}
+		if strings.Contains(usn, "uuid:") {
+			uuidStart := strings.Index(usn, "uuid:")
+			if uuidStart != -1 {
+				uuidPart := usn[uuidStart+5:]
+				if uuidEnd := strings.Index(uuidPart, "::"); uuidEnd != -1 {
+					uuid := uuidPart[:uuidEnd]
+					return fmt.Sprintf("Device %s", uuid[:min(8, len(uuid))])
+				}
+			}
+		}
+	}
+
+	switch deviceType {
+	case "urn:schemas-upnp-org:device:MediaServer:1":
+		return "Media Server"
+	case "urn:schemas-upnp-org:device:MediaRenderer:1":
+		return "Media Renderer"
+	case "upnp:rootdevice":
+		return "UPnP Device"
+	case "urn:schemas-upnp-org:device:basic:1":
+		return "Basic Device"
+	default:
+		return "Unknown Device"
+	}
+}
+
+func extractBaseURL(location string) string {
+	if strings.HasPrefix(location, "http://") {
+		parts := strings.Split(location[7:], "/")
+		if len(parts) > 0 {
+			hostPort := parts[0]
+			if !strings.Contains(hostPort, ":") {
+				hostPort += ":80"
+			}
+			return "http://" + hostPort
+		}
+	}
+	return location
+}
+
+// resolveKnownServiceURLs fetches the device description at location
+// (the SSDP LOCATION header) and resolves the control URLs of its
+// ContentDirectory and AVTransport services, if advertised. Either return
+// value is "" if the device doesn't expose that service.
+func resolveKnownServiceURLs(location, baseURL string) (contentDirURL, avTransportURL string) {
+	desc, err := fetchDeviceDescription(location)
+	if err != nil {
+		return "", ""
+	}
+	return resolveServiceControlURL(desc, baseURL, "ContentDirectory"),
+		resolveServiceControlURL(desc, baseURL, "AVTransport")
+}
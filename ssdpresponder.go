@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ssdpMulticastAddr is the standard SSDP multicast group and port every
+// control point listens on and every root device announces itself to.
+const ssdpMulticastAddr = "239.255.255.250:1900"
+
+// ssdpMaxAge is the CACHE-CONTROL max-age mop's MediaServer advertises, in
+// seconds. ssdpNotifyInterval re-announces well before it expires so a
+// control point never has to wait out a full max-age to notice mop is
+// still alive.
+const ssdpMaxAge = 1800
+
+const ssdpNotifyInterval = (ssdpMaxAge / 2) * time.Second
+
+// SSDPResponder answers M-SEARCH requests for the device/service types
+// MediaServer advertises (upnp:rootdevice, MediaServer:1, and
+// ContentDirectory:1), and periodically sends NOTIFY ssdp:alive the way
+// any other SSDP root device does, plus ssdp:byebye on shutdown.
+type SSDPResponder struct {
+	server *MediaServer
+	conn   *net.UDPConn
+}
+
+// NewSSDPResponder creates a responder for server; call Run to start
+// listening and announcing.
+func NewSSDPResponder(server *MediaServer) *SSDPResponder {
+	return &SSDPResponder{server: server}
+}
+
+// Run joins the SSDP multicast group, answers M-SEARCH requests, and sends
+// periodic NOTIFY ssdp:alive announcements until ctx is cancelled, sending
+// ssdp:byebye before it returns. It blocks, so callers should invoke it in
+// its own goroutine.
+func (r *SSDPResponder) Run(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, addr)
+	if err != nil {
+		return fmt.Errorf("joining SSDP multicast group: %w", err)
+	}
+	r.conn = conn
+	defer conn.Close()
+
+	go r.announceLoop(ctx)
+
+	buffer := make([]byte, 4096)
+	for {
+		select {
+		case <-ctx.Done():
+			r.sendByebye()
+			return nil
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			continue
+		}
+		r.handleSearch(string(buffer[:n]), from)
+	}
+}
+
+// advertisedTypes are the NT/ST values mop's MediaServer answers for and
+// announces itself under.
+func (r *SSDPResponder) advertisedTypes() []string {
+	return []string{"upnp:rootdevice", mediaServerDeviceType, contentDirectoryServiceType}
+}
+
+// handleSearch replies to an M-SEARCH request whose ST matches one of
+// advertisedTypes (or "ssdp:all"), ignoring anything else on the
+// multicast group the way a real device would.
+func (r *SSDPResponder) handleSearch(request string, from *net.UDPAddr) {
+	if !strings.HasPrefix(request, "M-SEARCH") {
+		return
+	}
+
+	var st string
+	for _, line := range strings.Split(request, "\r\n") {
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(line[:idx]), "ST") {
+			st = strings.TrimSpace(line[idx+1:])
+		}
+	}
+
+	for _, nt := range r.advertisedTypes() {
+		if st == "ssdp:all" || st == nt {
+			r.respondTo(from, nt)
+		}
+	}
+}
+
+func (r *SSDPResponder) respondTo(to *net.UDPAddr, st string) {
+	response := "HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+		"EXT:\r\n" +
+		"LOCATION: " + r.server.BaseURL + "/description.xml\r\n" +
+		"SERVER: mop/1.0 UPnP/1.0 DLNADOC/1.50\r\n" +
+		"ST: " + st + "\r\n" +
+		"USN: " + r.usn(st) + "\r\n\r\n"
+	r.conn.WriteToUDP([]byte(response), to)
+}
+
+// announceLoop sends an initial ssdp:alive burst and then repeats it every
+// ssdpNotifyInterval until ctx is cancelled.
+func (r *SSDPResponder) announceLoop(ctx context.Context) {
+	ticker := time.NewTicker(ssdpNotifyInterval)
+	defer ticker.Stop()
+
+	r.sendAlive()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.sendAlive()
+		}
+	}
+}
+
+func (r *SSDPResponder) sendAlive() {
+	for _, nt := range r.advertisedTypes() {
+		r.sendNotify(nt, "ssdp:alive")
+	}
+}
+
+func (r *SSDPResponder) sendByebye() {
+	for _, nt := range r.advertisedTypes() {
+		r.sendNotify(nt, "ssdp:byebye")
+	}
+}
+
+func (r *SSDPResponder) sendNotify(nt, subtype string) {
+	dest, err := net.ResolveUDPAddr("udp4", ssdpMulticastAddr)
+	if err != nil {
+		return
+	}
+
+	notify := "NOTIFY * HTTP/1.1\r\n" +
+		"HOST: " + ssdpMulticastAddr + "\r\n" +
+		"CACHE-CONTROL: max-age=" + strconv.Itoa(ssdpMaxAge) + "\r\n" +
+		"LOCATION: " + r.server.BaseURL + "/description.xml\r\n" +
+		"NT: " + nt + "\r\n" +
+		"NTS: " + subtype + "\r\n" +
+		"SERVER: mop/1.0 UPnP/1.0 DLNADOC/1.50\r\n" +
+		"USN: " + r.usn(nt) + "\r\n\r\n"
+
+	r.conn.WriteToUDP([]byte(notify), dest)
+}
+
+// usn builds the USN header for nt, per the UPnP Device Architecture
+// spec's "UDN::nt" composite USN format.
+func (r *SSDPResponder) usn(nt string) string {
+	return r.server.UDN + "::" + nt
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 // AppState represents the current state of the application
 type AppState int
@@ -8,6 +13,10 @@ const (
 	StateServerList AppState = iota
 	StateDirectoryBrowser
 	StateFileDetails
+	StateRendererSelect
+	StateTransportControl
+	StateQueue
+	StateSearch
 )
 
 // SettingsField represents which field is being edited in settings
@@ -18,28 +27,83 @@ const (
 	FieldCloseOnRun
 )
 
+// OpState represents a transient UI overlay layered on top of the main
+// AppState navigation: help, settings, and the per-item context menu. It
+// replaces what used to be a handful of independent booleans
+// (showHelp/showSettings/settingsEditing), so "what does this key do
+// right now" is always answered by a single value instead of several
+// flags that could disagree with each other.
+type OpState int
+
+const (
+	OpIdle OpState = iota
+	OpHelp
+	OpSettings
+	OpSettingsEditing
+	OpItemMenu
+	OpConfirmPlay
+	OpDownload
+	OpCopyURL
+	OpEnqueue
+)
+
+// menuEntry is one row of the "m" context menu opened on a selected
+// DirectoryItem. op is the OpState performOperation dispatches on when
+// this entry is chosen; "Show metadata" has no OpState of its own since
+// it just switches to the existing StateFileDetails view.
+type menuEntry struct {
+	label string
+	op    OpState
+}
+
+var itemMenuEntries = []menuEntry{
+	{"Play", OpConfirmPlay},
+	{"Enqueue", OpEnqueue},
+	{"Copy URL", OpCopyURL},
+	{"Download to local path", OpDownload},
+	{"Show metadata", OpIdle},
+}
+
 // UpnpDevice represents a discovered UPnP device
 type UpnpDevice struct {
-	Name                 string
-	Location             string
-	BaseURL              string
-	DeviceClient         string
-	ContentDirectoryURL  string
+	Name                string
+	Location            string
+	BaseURL             string
+	DeviceClient        string
+	ContentDirectoryURL string
+	AVTransportURL      string
+	DeviceType          string
+
+	// MaxAge is the device's SSDP CACHE-CONTROL lease length, i.e. how
+	// long devicecache.go should consider a cached sighting of it valid
+	// before re-verifying it. ssdpDefaultMaxAge when the SSDP response it
+	// was built from didn't carry one.
+	MaxAge time.Duration
+}
+
+// IsMediaRenderer reports whether this device advertised itself as a
+// MediaRenderer (rather than, or in addition to, a MediaServer) during
+// discovery.
+func (d UpnpDevice) IsMediaRenderer() bool {
+	return strings.Contains(d.DeviceType, "MediaRenderer") || d.AVTransportURL != ""
 }
 
 // DirectoryItem represents an item in a directory listing
 type DirectoryItem struct {
-	Name       string
+	ID          string // DIDL-Lite object ID, used to browse into a container without re-resolving it by name
+	Name        string
 	IsDirectory bool
-	URL        string
-	Metadata   *FileMetadata
+	URL         string
+	Metadata    *FileMetadata
 }
 
 // FileMetadata contains file information
 type FileMetadata struct {
-	Size     *uint64
-	Duration *string
-	Format   *string
+	Size       *uint64
+	Duration   *string
+	Format     *string
+	Bitrate    *uint64
+	Resolution *string
 }
 
 // DiscoveryMessage represents messages from the discovery process
@@ -49,6 +113,39 @@ type DiscoveryMessage struct {
 	Error     string
 	Completed bool
 	Devices   []UpnpDevice
+
+	// SearchResult is set when Type == "search_result": one match from the
+	// recursive walk rooted at the directory search.go started from.
+	SearchResult *SearchResult
+
+	// DownloadProgress is set when Type == "download_progress": the
+	// running byte count for the file a download.go transfer is
+	// currently streaming.
+	DownloadProgress *DownloadProgress
+}
+
+// DownloadProgress reports how far an in-flight download.go transfer has
+// gotten through its current file, for the OpDownload progress overlay.
+type DownloadProgress struct {
+	File       string
+	BytesDone  int64
+	TotalBytes int64
+}
+
+// SearchResult is a single recursive-search match, annotated with enough
+// to jump back to where it was found: ServerIndex is into the combined
+// servers+mounts list a.selectedServer navigates (see serverCount/
+// currentFS), so a match found while browsing a [[mop.mount]] jumps back
+// the same way one found on a UPnP server does. ServerName is captured at
+// match time for display, in case the entry at ServerIndex is gone by the
+// time the result is selected. Path is the virtual directory it was found
+// in, so StateSearch's Enter handler can jump straight there with Item
+// preselected instead of only the source's root.
+type SearchResult struct {
+	Item        DirectoryItem
+	Path        []string
+	ServerIndex int
+	ServerName  string
 }
 
 // App represents the main application state
@@ -63,16 +160,49 @@ type App struct {
 	lastError            string
 	discoveryErrors      []string
 	isDiscovering        bool
-	showHelp             bool
-	showSettings         bool
-	settingsEditing      bool
+	opState              OpState
 	settingsField        SettingsField
 	settingsInput        string
+	menuTarget           *DirectoryItem // item the "m" context menu is currently open for
+	menuSelectedItem     int
 	containerIDMap       map[string]string // path -> container ID mapping
 	config               *Config
 	discoveryChan        chan DiscoveryMessage
 	width                int
 	height               int
+
+	renderers        []UpnpDevice // MediaRenderer devices found during discovery
+	selectedRenderer int
+	castItem         *DirectoryItem // item pending renderer selection
+	castingTo        *UpnpDevice    // renderer currently playing castItem
+	transportState   string         // "PLAYING", "PAUSED_PLAYBACK", "STOPPED", ...
+
+	Queue             []DirectoryItem // playback queue, persisted via Config
+	queueSelectedItem int
+	mpvSession        *MpvSession // persistent mpv IPC session fed by the queue when CloseOnRun is false
+
+	searchInput        string
+	searching          bool
+	searchStarted      bool
+	searchResults      []SearchResult
+	searchSelectedItem int
+	searchCancel       context.CancelFunc // cancels the in-flight recursive walk; nil when idle
+	browseCache        *lruCache          // FS.List results by FS.URI()#path (see browseCached), shared across searches
+
+	rediscover bool // mop --rediscover: skip the on-disk device cache on startup
+
+	controlClient *ControlClient // non-nil when relaying discovery from an already-running mopd instead of running our own Scanner; see App.startDiscovery
+
+	download *downloadState // in-flight download.go transfer backing the OpDownload progress overlay, nil when idle
+
+	mounts []mountEntry // config [[mop.mount]] entries, shown in renderServerList after the SSDP-discovered servers
+}
+
+// mountEntry is one config [[mop.mount]] resolved to its FS, as held by
+// App.mounts.
+type mountEntry struct {
+	Name string
+	FS   FS
 }
 
 // KeyMappings contains the help text for key bindings
@@ -85,6 +215,13 @@ type KeyMappings struct {
 	Help            string
 	Settings        string
 	Quit            string
+	Cast            string
+	Enqueue         string
+	ShowQueue       string
+	Search          string
+	ItemMenu        string
+	DownloadLocal   string
+	DownloadTar     string
 }
 
 var Keys = KeyMappings{
@@ -96,6 +233,16 @@ var Keys = KeyMappings{
 	Help:            "?: help",
 	Settings:        ",: settings",
 	Quit:            "q: quit",
+	Cast:            "c: cast to renderer",
+	// Enqueue uses "a"/"A" rather than "q" since "q" is the global quit key
+	// above; the queue/mpv-IPC subsystem itself predates this binding
+	// choice (see queue.go, mpvipc.go).
+	Enqueue:       "a: enqueue, A: play next",
+	ShowQueue:     "Q: queue",
+	Search:        "/: search this directory",
+	ItemMenu:      "m: item menu",
+	DownloadLocal: "d: download",
+	DownloadTar:   "D: download as tar",
 }
 
 const ErrorKey = "e: dump errors"
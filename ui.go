@@ -42,12 +42,15 @@ var (
 )
 
 func (a *App) View() string {
-	if a.showHelp {
+	switch a.opState {
+	case OpHelp:
 		return a.renderHelp()
-	}
-
-	if a.showSettings {
+	case OpSettings, OpSettingsEditing:
 		return a.renderSettings()
+	case OpItemMenu:
+		return a.renderItemMenu()
+	case OpDownload:
+		return a.renderDownloadProgress()
 	}
 
 	return a.renderMain()
@@ -68,6 +71,14 @@ func (a *App) renderMain() string {
 		content.WriteString(a.renderDirectoryBrowser())
 	case StateFileDetails:
 		content.WriteString(a.renderFileDetails())
+	case StateRendererSelect:
+		content.WriteString(a.renderRendererSelect())
+	case StateTransportControl:
+		content.WriteString(a.renderTransportControl())
+	case StateQueue:
+		content.WriteString(a.renderQueue())
+	case StateSearch:
+		content.WriteString(a.renderSearch())
 	}
 
 	// Help text
@@ -87,15 +98,15 @@ func (a *App) renderServerList() string {
 	var content strings.Builder
 
 	// Title with discovery status
-	title := "[ ] Discovered UPnP Devices"
+	title := "[ ] Servers"
 	if a.isDiscovering {
-		title = "[•] Discovered UPnP Devices"
+		title = "[•] Servers"
 	}
 	content.WriteString(titleStyle.Render(title))
 	content.WriteString("\n\n")
 
 	// Server list
-	if len(a.servers) == 0 {
+	if a.serverCount() == 0 {
 		if a.isDiscovering {
 			content.WriteString("Discovering devices...")
 		} else {
@@ -104,24 +115,25 @@ func (a *App) renderServerList() string {
 	} else {
 		// Calculate layout dimensions
 		leftWidth := a.width / 2
-		
-		// Left side - clean device list
+
+		// Left side - clean device list, SSDP-discovered servers followed
+		// by configured [[mop.mount]] entries
 		leftContent := strings.Builder{}
-		for i, server := range a.servers {
+		for i := 0; i < a.serverCount(); i++ {
 			prefix := "  "
 			style := lipgloss.NewStyle()
-			
+
 			if i == a.selectedServer {
 				prefix = "> "
 				style = selectedStyle
 			}
-			
+
 			// Show only clean device name
-			line := fmt.Sprintf("%s%s", prefix, server.Name)
+			line := fmt.Sprintf("%s%s", prefix, a.serverDisplayName(i))
 			leftContent.WriteString(style.Render(line))
 			leftContent.WriteString("\n")
 		}
-		
+
 		// Right side - device details
 		rightContent := strings.Builder{}
 		if a.selectedServer >= 0 && a.selectedServer < len(a.servers) {
@@ -134,6 +146,11 @@ func (a *App) renderServerList() string {
 			if server.BaseURL != "" {
 				rightContent.WriteString(fmt.Sprintf("Base: %s\n", server.BaseURL))
 			}
+		} else if fs, err := a.currentFS(); err == nil {
+			rightContent.WriteString(infoStyle.Render("Mount Details:"))
+			rightContent.WriteString("\n\n")
+			rightContent.WriteString(fmt.Sprintf("Name: %s\n", a.serverDisplayName(a.selectedServer)))
+			rightContent.WriteString(fmt.Sprintf("URI: %s\n", fs.URI()))
 		}
 		
 		// Combine left and right sides
@@ -244,6 +261,125 @@ func (a *App) renderFileDetails() string {
 			if item.Metadata.Format != nil {
 				content.WriteString(fmt.Sprintf("Format: %s\n", *item.Metadata.Format))
 			}
+			if item.Metadata.Bitrate != nil {
+				content.WriteString(fmt.Sprintf("Bitrate: %d\n", *item.Metadata.Bitrate))
+			}
+			if item.Metadata.Resolution != nil {
+				content.WriteString(fmt.Sprintf("Resolution: %s\n", *item.Metadata.Resolution))
+			}
+		}
+	}
+
+	return content.String()
+}
+
+func (a *App) renderRendererSelect() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Cast to Renderer"))
+	content.WriteString("\n\n")
+
+	if a.castItem != nil {
+		content.WriteString(infoStyle.Render(fmt.Sprintf("File: %s", a.castItem.Name)))
+		content.WriteString("\n\n")
+	}
+
+	for i, renderer := range a.renderers {
+		prefix := "  "
+		style := lipgloss.NewStyle()
+
+		if i == a.selectedRenderer {
+			prefix = "> "
+			style = selectedStyle
+		}
+
+		content.WriteString(style.Render(fmt.Sprintf("%s%s", prefix, renderer.Name)))
+		content.WriteString("\n")
+	}
+
+	return content.String()
+}
+
+func (a *App) renderTransportControl() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Casting"))
+	content.WriteString("\n\n")
+
+	if a.castingTo != nil {
+		content.WriteString(fmt.Sprintf("Renderer: %s\n", a.castingTo.Name))
+	}
+	if a.castItem != nil {
+		content.WriteString(fmt.Sprintf("File: %s\n", a.castItem.Name))
+	}
+	content.WriteString(fmt.Sprintf("State: %s\n", a.transportState))
+
+	return content.String()
+}
+
+func (a *App) renderQueue() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Queue"))
+	content.WriteString("\n\n")
+
+	if len(a.Queue) == 0 {
+		content.WriteString("Queue is empty. Press 'a' on a file to enqueue it.")
+	} else {
+		for i, item := range a.Queue {
+			prefix := "  "
+			style := lipgloss.NewStyle()
+
+			if i == a.queueSelectedItem {
+				prefix = "> "
+				style = selectedStyle
+			}
+
+			content.WriteString(style.Render(fmt.Sprintf("%s%d. %s", prefix, i+1, item.Name)))
+			content.WriteString("\n")
+		}
+	}
+
+	return content.String()
+}
+
+func (a *App) renderSearch() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Search This Directory"))
+	content.WriteString("\n\n")
+
+	content.WriteString(infoStyle.Render(fmt.Sprintf("Query: %s_", a.searchInput)))
+	content.WriteString("\n\n")
+
+	if !a.searchStarted {
+		content.WriteString("Type a query and press enter to walk this directory's subtree.\n")
+		content.WriteString("Prefix with re: for a regexp, e.g. re:^S\\d+E\\d+\n")
+		content.WriteString("Prefix with all: to search every known UPnP server instead.")
+		return content.String()
+	}
+
+	if a.searching {
+		content.WriteString("Searching...\n\n")
+	}
+
+	if len(a.searchResults) == 0 {
+		if !a.searching {
+			content.WriteString("No matches found.")
+		}
+	} else {
+		for i, result := range a.searchResults {
+			prefix := "  "
+			style := lipgloss.NewStyle()
+
+			if i == a.searchSelectedItem {
+				prefix = "> "
+				style = selectedStyle
+			}
+
+			fullPath := strings.Join(append(append([]string{}, result.Path...), result.Item.Name), "/")
+			content.WriteString(style.Render(fmt.Sprintf("%s%s  [%s]", prefix, fullPath, result.ServerName)))
+			content.WriteString("\n")
 		}
 	}
 
@@ -276,6 +412,12 @@ func (a *App) renderFileInfo() string {
 			if item.Metadata.Format != nil {
 				content.WriteString(fmt.Sprintf("Format: %s\n", *item.Metadata.Format))
 			}
+			if item.Metadata.Bitrate != nil {
+				content.WriteString(fmt.Sprintf("Bitrate: %d\n", *item.Metadata.Bitrate))
+			}
+			if item.Metadata.Resolution != nil {
+				content.WriteString(fmt.Sprintf("Resolution: %s\n", *item.Metadata.Resolution))
+			}
 		}
 	}
 
@@ -295,11 +437,23 @@ func (a *App) renderHelpText() string {
 				Keys.Navigate, Keys.SelectServer, Keys.Help, Keys.Settings, Keys.Quit)
 		}
 	case StateDirectoryBrowser:
-		helpText = fmt.Sprintf("─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────",
-			Keys.Navigate, Keys.Open, Keys.Back, Keys.Help, Keys.Settings, Keys.Quit)
+		helpText = fmt.Sprintf("─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────",
+			Keys.Navigate, Keys.Open, Keys.ItemMenu, Keys.Cast, Keys.Enqueue, Keys.ShowQueue, Keys.Search, Keys.Back, Keys.Help, Keys.Quit)
 	case StateFileDetails:
-		helpText = fmt.Sprintf("─────| %s |─────| %s |─────| %s |─────| %s |─────",
-			Keys.BackToDirectory, Keys.Help, Keys.Settings, Keys.Quit)
+		helpText = fmt.Sprintf("─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────| %s |─────",
+			Keys.BackToDirectory, Keys.DownloadLocal, Keys.DownloadTar, Keys.Help, Keys.Settings, Keys.Quit)
+	case StateRendererSelect:
+		helpText = fmt.Sprintf("─────| %s |─────| enter: cast |─────| backspace: cancel |─────", Keys.Navigate)
+	case StateTransportControl:
+		helpText = "─────| space: play/pause |─────| s: stop |─────| ←/→: skip |─────| backspace: back |─────"
+	case StateQueue:
+		helpText = fmt.Sprintf("─────| %s |─────| enter: play |─────| x: clear |─────| backspace: back |─────", Keys.Navigate)
+	case StateSearch:
+		if a.searchStarted {
+			helpText = fmt.Sprintf("─────| %s |─────| enter: jump to item |─────| esc: cancel/back |─────", Keys.Navigate)
+		} else {
+			helpText = "─────| type to search |─────| enter: search |─────| esc: cancel |─────"
+		}
 	}
 
 	return helpStyle.Render(helpText)
@@ -338,6 +492,13 @@ Keys:
 ` + Keys.Navigate + `
 ` + Keys.SelectServer + `
 ` + Keys.Open + `
+` + Keys.ItemMenu + `
+` + Keys.DownloadLocal + `
+` + Keys.DownloadTar + `
+` + Keys.Cast + `
+` + Keys.Enqueue + `
+` + Keys.ShowQueue + `
+` + Keys.Search + `
 ` + Keys.Back + `
 ` + Keys.Help + `
 ` + Keys.Settings + `
@@ -355,7 +516,7 @@ func (a *App) renderSettings() string {
 	content.WriteString(titleStyle.Render("Settings"))
 	content.WriteString("\n\n")
 
-	if a.settingsEditing {
+	if a.opState == OpSettingsEditing {
 		// Show input field
 		fieldName := "Player"
 		if a.settingsField == FieldCloseOnRun {
@@ -364,7 +525,11 @@ func (a *App) renderSettings() string {
 		
 		content.WriteString(fmt.Sprintf("%s: %s_", fieldName, a.settingsInput))
 		content.WriteString("\n\n")
-		content.WriteString("Press Enter to save, Esc to cancel")
+		if a.settingsField == FieldPlayer {
+			content.WriteString("←/→: select player, Enter to save, Esc to cancel")
+		} else {
+			content.WriteString("Press Enter to save, Esc to cancel")
+		}
 	} else {
 		// Show settings overview
 		playerStyle := lipgloss.NewStyle()
@@ -395,6 +560,59 @@ func (a *App) renderSettings() string {
 	return modalStyle.Render(content.String())
 }
 
+func (a *App) renderItemMenu() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Item Menu"))
+	content.WriteString("\n\n")
+
+	if a.menuTarget != nil {
+		content.WriteString(infoStyle.Render(fmt.Sprintf("Item: %s", a.menuTarget.Name)))
+		content.WriteString("\n\n")
+	}
+
+	for i, entry := range itemMenuEntries {
+		prefix := "  "
+		style := lipgloss.NewStyle()
+
+		if i == a.menuSelectedItem {
+			prefix = "> "
+			style = selectedStyle
+		}
+
+		content.WriteString(style.Render(fmt.Sprintf("%s%s", prefix, entry.label)))
+		content.WriteString("\n")
+	}
+
+	content.WriteString("\n")
+	content.WriteString("↑↓: navigate, enter: select, esc/m: close")
+
+	return modalStyle.Render(content.String())
+}
+
+// renderDownloadProgress shows the file currently streaming and a
+// bytes-done/bytes-total readout for the in-flight startDownload
+// transfer, while OpDownload is the active overlay.
+func (a *App) renderDownloadProgress() string {
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Downloading"))
+	content.WriteString("\n\n")
+
+	if a.download != nil {
+		content.WriteString(fmt.Sprintf("File: %s\n", a.download.file))
+		if a.download.total > 0 {
+			content.WriteString(fmt.Sprintf("%s / %s\n", formatSize(uint64(a.download.done)), formatSize(uint64(a.download.total))))
+		} else {
+			content.WriteString(fmt.Sprintf("%s\n", formatSize(uint64(a.download.done))))
+		}
+	}
+
+	content.WriteString("\nesc: cancel")
+
+	return modalStyle.Render(content.String())
+}
+
 func formatSize(bytes uint64) string {
 	units := []string{"B", "KB", "MB", "GB", "TB"}
 	size := float64(bytes)